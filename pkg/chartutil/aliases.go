@@ -0,0 +1,126 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	goyaml "gopkg.in/yaml.v2"
+)
+
+// ErrAlias indicates that a YAML document uses an anchor or an alias where
+// they are not allowed.
+type ErrAlias struct {
+	// Line is the line of the anchor or alias, starting at 1.
+	Line int
+	// Token is the anchor or alias, such as "&defaults" or "*defaults".
+	Token string
+}
+
+func (e ErrAlias) Error() string {
+	return fmt.Sprintf("yaml: line %d: anchors and aliases are not allowed: %s", e.Line, e.Token)
+}
+
+// ReadValuesNoAliases will parse YAML byte data into a Values like
+// ReadValues, but rejects documents that use anchors or aliases.
+//
+// Aliases let a small document expand into a very large one, so this is meant
+// for values from untrusted sources. An alias can only refer to an anchor
+// earlier in the document, so the error is for the first anchor. An alias to
+// an undefined anchor is not valid YAML and fails to parse. Aliases are not
+// expanded while checking.
+func ReadValuesNoAliases(data []byte) (Values, error) {
+	if err := findAlias(data); err != nil {
+		return Values{}, err
+	}
+	return ReadValues(data)
+}
+
+// findAlias returns an ErrAlias for the first anchor in data, or the parse
+// error if data is not valid YAML.
+//
+// The YAML parser does not expose anchors, so they are found by their effect:
+// changing the '&' of an anchor to '*' turns it into an alias to an undefined
+// anchor, which fails to parse, while an '&' in a scalar, comment or tag is
+// text either way. Aliases need an anchor before them, so finding the first
+// anchor is enough.
+func findAlias(data []byte) error {
+	if err := parseYAML(data); err != nil {
+		return err
+	}
+	var amps []int
+	for i, c := range data {
+		if c == '&' {
+			amps = append(amps, i)
+		}
+	}
+	if parseYAML(withAliases(data, amps)) == nil {
+		return nil
+	}
+	// Changing an '&' that is not an anchor never breaks the document, so the
+	// first prefix of changes that does ends at the first anchor.
+	n := sort.Search(len(amps), func(n int) bool {
+		return parseYAML(withAliases(data, amps[:n+1])) != nil
+	})
+	if n == len(amps) {
+		return nil
+	}
+	start := amps[n]
+	end := start + 1
+	for end < len(data) && isAnchorChar(data[end]) {
+		end++
+	}
+	return ErrAlias{Line: bytes.Count(data[:start], []byte("\n")) + 1, Token: string(data[start:end])}
+}
+
+// withAliases returns a copy of data with the '&' at each of the given offsets
+// changed to '*'.
+func withAliases(data []byte, offsets []int) []byte {
+	out := append([]byte(nil), data...)
+	for _, i := range offsets {
+		out[i] = '*'
+	}
+	return out
+}
+
+// isAnchorChar reports whether c may appear in an anchor name.
+func isAnchorChar(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '-'
+}
+
+// parseYAML parses every document in data without decoding them, so aliases
+// are not expanded.
+func parseYAML(data []byte) error {
+	dec := goyaml.NewDecoder(bytes.NewReader(data))
+	for {
+		if err := dec.Decode(&discardYAML{}); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+// discardYAML is a yaml.Unmarshaler that ignores the node it is given.
+type discardYAML struct{}
+
+func (discardYAML) UnmarshalYAML(func(interface{}) error) error {
+	return nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReadValuesNoAliases(t *testing.T) {
+	rejected := []struct {
+		doc   string
+		line  int
+		token string
+	}{
+		{"defaults: &defaults\n  tag: v1\n", 1, "&defaults"},
+		{"crew:\n- Ishmael\n- &ahab Ahab\n- *ahab\n", 3, "&ahab"},
+		{"crew: [Ishmael, &ahab Ahab, *ahab]\n", 1, "&ahab"},
+		{"ship: {name: Pequod, captain: &ahab Ahab}\n", 1, "&ahab"},
+		{"captain: !!map &ahab\n  name: Ahab\n", 1, "&ahab"},
+		{"--- &doc\nname: moby\n", 1, "&doc"},
+		{"name: moby\n---\nname: &dick dick\n", 3, "&dick"},
+		{"list:\n- a: >\n    x\n  b: &anc 1\n  c: *anc\n", 4, "&anc"},
+		{"list:\n- a: |\n    x\n  b: &anc 1\n  c: *anc\n", 4, "&anc"},
+	}
+	for _, tt := range rejected {
+		_, err := ReadValuesNoAliases([]byte(tt.doc))
+		aliasErr, ok := err.(ErrAlias)
+		if !ok {
+			t.Errorf("Expected ErrAlias for %q, got %v", tt.doc, err)
+			continue
+		}
+		if aliasErr.Line != tt.line || aliasErr.Token != tt.token {
+			t.Errorf("Expected %s on line %d for %q, got %s on line %d", tt.token, tt.line, tt.doc, aliasErr.Token, aliasErr.Line)
+		}
+	}
+
+	// An alias to an undefined anchor is not valid YAML.
+	for _, doc := range []string{
+		"web:\n  <<: *defaults\n",
+		"crew: [Ishmael, *ahab]\n",
+		"*ahab : captain\n",
+	} {
+		if _, err := ReadValuesNoAliases([]byte(doc)); err == nil {
+			t.Errorf("Expected an error for %q", doc)
+		}
+	}
+
+	// Expanding these aliases would make a table of a billion entries.
+	var laughs strings.Builder
+	laughs.WriteString("a: &a [lol, lol, lol, lol, lol, lol, lol, lol, lol, lol]\n")
+	for c := 'b'; c <= 'i'; c++ {
+		fmt.Fprintf(&laughs, "%c: &%c [", c, c)
+		for i := 0; i < 10; i++ {
+			fmt.Fprintf(&laughs, "*%c, ", c-1)
+		}
+		laughs.WriteString("x]\n")
+	}
+	if _, err := ReadValuesNoAliases([]byte(laughs.String())); err != (ErrAlias{Line: 1, Token: "&a"}) {
+		t.Errorf("Expected ErrAlias for &a, got %v", err)
+	}
+
+	allowed := `# Aliases such as *ahab in comments are fine.
+name: Tom & Jerry
+host: "*.example.com"
+glob: '*'
+quote: 'it''s *not* an alias'
+multiline: "a quoted string
+  with &amp on a new line"
+sum: 3*4
+crew: [Ishmael, "*ahab"]
+poem: |
+  *In Xanadu* did Kubla Khan
+  & so on
+query: a=1&b=2&c=*
+tail: done
+`
+	v, err := ReadValuesNoAliases([]byte(allowed))
+	if err != nil {
+		t.Fatalf("Expected no error, got %s", err)
+	}
+	expect := map[string]string{
+		"name":  "Tom & Jerry",
+		"host":  "*.example.com",
+		"glob":  "*",
+		"sum":   "3*4",
+		"query": "a=1&b=2&c=*",
+		"poem":  "*In Xanadu* did Kubla Khan\n& so on\n",
+		"tail":  "done",
+	}
+	for k, want := range expect {
+		if got := v[k]; got != want {
+			t.Errorf("Expected %s to be %q, got %q", k, want, got)
+		}
+	}
+}
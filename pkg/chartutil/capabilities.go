@@ -16,9 +16,13 @@ limitations under the License.
 package chartutil
 
 import (
+	"errors"
 	"fmt"
 	"runtime"
+	"sort"
+	"strings"
 
+	"github.com/Masterminds/semver"
 	"k8s.io/apimachinery/pkg/version"
 	tversion "k8s.io/helm/pkg/proto/hapi/version"
 )
@@ -42,6 +46,10 @@ var (
 type Capabilities struct {
 	// APIVersions list of all supported API versions
 	APIVersions VersionSet
+	// Kinds list of all supported resources, as "group/version/Kind" strings
+	// such as "networking.k8s.io/v1/Ingress", or "version/Kind" for the core
+	// group, such as "v1/Pod"
+	Kinds VersionSet
 	// KubeVersion is the Kubernetes version
 	KubeVersion *version.Info
 	// TillerVersion is the Tiller version
@@ -50,18 +58,63 @@ type Capabilities struct {
 	TillerVersion *tversion.Version
 }
 
+// KubeVersionSemVer returns the Kubernetes version as a semantic version.
+//
+// The version is built from the Major and Minor fields of KubeVersion. Some
+// providers report a Minor version with a trailing '+' (such as "16+"), which
+// is stripped before parsing.
+func (c *Capabilities) KubeVersionSemVer() (*semver.Version, error) {
+	if c.KubeVersion == nil {
+		return nil, errors.New("no Kubernetes version in capabilities")
+	}
+	minor := strings.TrimSuffix(c.KubeVersion.Minor, "+")
+	return semver.NewVersion(fmt.Sprintf("%s.%s", c.KubeVersion.Major, minor))
+}
+
+// HasResource returns true if the cluster supports the given resource, as a
+// "group/version/Kind" string.
+//
+//	caps.HasResource("networking.k8s.io/v1/Ingress")
+func (c *Capabilities) HasResource(gvk string) bool {
+	return c.Kinds.Has(gvk)
+}
+
+// GitVersion returns the Kubernetes version as reported by the cluster, such
+// as "v1.16.2", or an empty string if the version is unknown.
+func (c *Capabilities) GitVersion() string {
+	if c.KubeVersion == nil {
+		return ""
+	}
+	return c.KubeVersion.GitVersion
+}
+
 // VersionSet is a set of Kubernetes API versions.
 type VersionSet map[string]interface{}
 
 // NewVersionSet creates a new version set from a list of strings.
+//
+// Surrounding whitespace is trimmed from each version, empty versions are
+// skipped, and duplicates are stored once.
 func NewVersionSet(apiVersions ...string) VersionSet {
 	vs := VersionSet{}
 	for _, v := range apiVersions {
-		vs[v] = struct{}{}
+		if v = strings.TrimSpace(v); v != "" {
+			vs[v] = struct{}{}
+		}
 	}
 	return vs
 }
 
+// Slice returns the versions in the set, sorted.
+func (v VersionSet) Slice() []string {
+	versions := make([]string, 0, len(v))
+	for k := range v {
+		versions = append(versions, k)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
 // Has returns true if the version string is in the set.
 //
 //	vs.Has("extensions/v1beta1")
@@ -69,3 +122,27 @@ func (v VersionSet) Has(apiVersion string) bool {
 	_, ok := v[apiVersion]
 	return ok
 }
+
+// Remove returns a new version set without the given versions. Versions that
+// are not in the set are ignored.
+func (v VersionSet) Remove(apiVersions ...string) VersionSet {
+	vs := VersionSet{}
+	for k, val := range v {
+		vs[k] = val
+	}
+	for _, apiVersion := range apiVersions {
+		delete(vs, apiVersion)
+	}
+	return vs
+}
+
+// Difference returns a new version set with the versions in v that are not in other.
+func (v VersionSet) Difference(other VersionSet) VersionSet {
+	vs := VersionSet{}
+	for k, val := range v {
+		if !other.Has(k) {
+			vs[k] = val
+		}
+	}
+	return vs
+}
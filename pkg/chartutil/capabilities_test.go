@@ -16,7 +16,10 @@ limitations under the License.
 package chartutil
 
 import (
+	"reflect"
 	"testing"
+
+	"k8s.io/apimachinery/pkg/version"
 )
 
 func TestVersionSet(t *testing.T) {
@@ -34,6 +37,57 @@ func TestVersionSet(t *testing.T) {
 	}
 }
 
+func TestVersionSetSlice(t *testing.T) {
+	vs := NewVersionSet("v1", "apps/v1", " v1", "", "extensions/v1beta1", "apps/v1")
+	if len(vs) != 3 {
+		t.Errorf("Expected duplicates to collapse to 3 versions, got %d", len(vs))
+	}
+
+	expect := []string{"apps/v1", "extensions/v1beta1", "v1"}
+	if got := vs.Slice(); !reflect.DeepEqual(got, expect) {
+		t.Errorf("Expected %v, got %v", expect, got)
+	}
+
+	if got := (VersionSet{}).Slice(); len(got) != 0 {
+		t.Errorf("Expected an empty slice, got %v", got)
+	}
+}
+
+func TestVersionSetRemove(t *testing.T) {
+	vs := NewVersionSet("v1", "extensions/v1beta1", "apps/v1")
+
+	removed := vs.Remove("extensions/v1beta1", "Spanish/inquisition")
+	if d := len(removed); d != 2 {
+		t.Errorf("Expected 2 versions, got %d", d)
+	}
+	if removed.Has("extensions/v1beta1") {
+		t.Error("Expected extensions/v1beta1 to be removed")
+	}
+	if !removed.Has("v1") || !removed.Has("apps/v1") {
+		t.Error("Expected v1 and apps/v1 to be kept")
+	}
+	if !vs.Has("extensions/v1beta1") {
+		t.Error("Expected the original set to be unchanged")
+	}
+
+	if d := len(vs.Remove("Spanish/inquisition")); d != 3 {
+		t.Errorf("Expected removing an absent version to keep 3 versions, got %d", d)
+	}
+}
+
+func TestVersionSetDifference(t *testing.T) {
+	vs := NewVersionSet("v1", "extensions/v1beta1", "apps/v1")
+	other := NewVersionSet("v1", "apps/v1", "batch/v1")
+
+	diff := vs.Difference(other)
+	if d := len(diff); d != 1 {
+		t.Errorf("Expected 1 version, got %d", d)
+	}
+	if !diff.Has("extensions/v1beta1") {
+		t.Error("Expected to find extensions/v1beta1")
+	}
+}
+
 func TestDefaultVersionSet(t *testing.T) {
 	if !DefaultVersionSet.Has("v1") {
 		t.Error("Expected core v1 version set")
@@ -52,3 +106,53 @@ func TestCapabilities(t *testing.T) {
 		t.Error("APIVersions should have v1")
 	}
 }
+
+func TestCapabilitiesHasResource(t *testing.T) {
+	cap := Capabilities{
+		APIVersions: NewVersionSet("v1", "networking.k8s.io/v1"),
+		Kinds:       NewVersionSet("v1/Pod", "networking.k8s.io/v1/Ingress"),
+	}
+
+	if !cap.HasResource("networking.k8s.io/v1/Ingress") {
+		t.Error("Expected the Ingress kind to be supported")
+	}
+	if !cap.HasResource("v1/Pod") {
+		t.Error("Expected the Pod kind to be supported")
+	}
+	if cap.HasResource("networking.k8s.io/v1/NetworkPolicy") {
+		t.Error("Expected the NetworkPolicy kind not to be supported")
+	}
+	if cap.HasResource("networking.k8s.io/v1") {
+		t.Error("Expected an API version not to match a kind")
+	}
+
+	if (&Capabilities{APIVersions: DefaultVersionSet}).HasResource("v1/Pod") {
+		t.Error("Expected no kinds without a Kinds set")
+	}
+}
+
+func TestCapabilitiesKubeVersionSemVer(t *testing.T) {
+	for _, minor := range []string{"16", "16+"} {
+		cap := Capabilities{
+			KubeVersion: &version.Info{Major: "1", Minor: minor, GitVersion: "v1.16.2-gke.8"},
+		}
+		v, err := cap.KubeVersionSemVer()
+		if err != nil {
+			t.Fatalf("Minor %q: %s", minor, err)
+		}
+		if v.Major() != 1 || v.Minor() != 16 {
+			t.Errorf("Minor %q: expected 1.16, got %s", minor, v)
+		}
+		if gv := cap.GitVersion(); gv != "v1.16.2-gke.8" {
+			t.Errorf("Expected git version v1.16.2-gke.8, got %q", gv)
+		}
+	}
+
+	cap := Capabilities{}
+	if _, err := cap.KubeVersionSemVer(); err == nil {
+		t.Error("Expected an error without a Kubernetes version")
+	}
+	if gv := cap.GitVersion(); gv != "" {
+		t.Errorf("Expected an empty git version, got %q", gv)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/Masterminds/semver"
 	"github.com/ghodss/yaml"
 
 	"k8s.io/helm/pkg/proto/hapi/chart"
@@ -63,6 +64,25 @@ func SaveChartfile(filename string, cf *chart.Metadata) error {
 	return ioutil.WriteFile(filename, out, 0644)
 }
 
+// ValidateMetadata checks that chart metadata has the required fields.
+//
+// The name and version must be set, and the version must be a valid SemVer.
+func ValidateMetadata(m *chart.Metadata) error {
+	if m == nil {
+		return errors.New("chart metadata (Chart.yaml) missing")
+	}
+	if m.Name == "" {
+		return errors.New("invalid chart (Chart.yaml): name must not be empty")
+	}
+	if m.Version == "" {
+		return fmt.Errorf("invalid chart %q (Chart.yaml): version must not be empty", m.Name)
+	}
+	if _, err := semver.NewVersion(m.Version); err != nil {
+		return fmt.Errorf("invalid chart %q (Chart.yaml): version %q is not a valid SemVer", m.Name, m.Version)
+	}
+	return nil
+}
+
 // IsChartDir validate a chart directory.
 //
 // Checks for a valid Chart.yaml.
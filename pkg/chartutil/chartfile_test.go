@@ -108,6 +108,32 @@ func verifyChartfile(t *testing.T, f *chart.Metadata, name string) {
 	}
 }
 
+func TestValidateMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		md   *chart.Metadata
+		err  string
+	}{
+		{"valid", &chart.Metadata{Name: "frobnitz", Version: "1.2.3"}, ""},
+		{"nil", nil, "chart metadata (Chart.yaml) missing"},
+		{"no name", &chart.Metadata{Version: "1.2.3"}, "invalid chart (Chart.yaml): name must not be empty"},
+		{"no version", &chart.Metadata{Name: "frobnitz"}, `invalid chart "frobnitz" (Chart.yaml): version must not be empty`},
+		{"bad version", &chart.Metadata{Name: "frobnitz", Version: "one"}, `invalid chart "frobnitz" (Chart.yaml): version "one" is not a valid SemVer`},
+	}
+	for _, tt := range tests {
+		err := ValidateMetadata(tt.md)
+		if tt.err == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", tt.name, err)
+			}
+			continue
+		}
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
 func TestIsChartDir(t *testing.T) {
 	validChartDir, err := IsChartDir("testdata/frobnitz")
 	if !validChartDir {
@@ -0,0 +1,224 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ChangeKind describes how a value changed between two sets of values.
+type ChangeKind string
+
+const (
+	// ChangeAdded indicates a value that is only present in the new values.
+	ChangeAdded ChangeKind = "added"
+	// ChangeRemoved indicates a value that is only present in the old values.
+	ChangeRemoved ChangeKind = "removed"
+	// ChangeModified indicates a value that is present in both, but differs.
+	ChangeModified ChangeKind = "modified"
+)
+
+// Change is a single difference between two sets of values.
+type Change struct {
+	// Path is the dotted path of the value that changed.
+	Path string
+	// Kind is the kind of change.
+	Kind ChangeKind
+	// Old is the old value. It is nil for an added value.
+	Old interface{}
+	// New is the new value. It is nil for a removed value.
+	New interface{}
+}
+
+// DiffOptions controls the behavior of Diff.
+type DiffOptions struct {
+	// IgnorePaths is a list of dotted paths that are left out of the diff.
+	//
+	// A path also ignores everything below it, so "image" ignores
+	// "image.tag". A "*" matches any single key, so "*.password" ignores the
+	// password of every top-level table.
+	IgnorePaths []string
+}
+
+// Diff computes the changes needed to go from oldVals to newVals.
+//
+// Nested tables are compared key by key. Any other value, including a list,
+// is compared as a whole, so a changed list is reported as a single
+// modification. A value that changes between a table and a non-table is also
+// reported as a single modification.
+//
+// The changes are returned in sorted key order, depth first.
+func Diff(oldVals, newVals Values, opts DiffOptions) []Change {
+	var changes []Change
+	diffTables("", oldVals.AsMap(), newVals.AsMap(), opts, &changes)
+	return changes
+}
+
+// Diff computes the changes needed to go from the Values to other, as the
+// Diff function does without any paths ignored.
+func (v Values) Diff(other Values) []Change {
+	return Diff(v, other, DiffOptions{})
+}
+
+// Subtract returns the values in v that differ from base, as a new tree.
+//
+// Nested tables are compared key by key, and only the leaves that are missing
+// from base, or that have a different value there, are kept. Any other value,
+// including a list, is compared as a whole. Tables left with no differences
+// are dropped. Keys that are in base but not in v are not represented.
+//
+// This is useful to keep only the user-supplied overrides from a set of
+// coalesced values, by subtracting the chart defaults.
+func (v Values) Subtract(base Values) Values {
+	return subtractTables(v.AsMap(), base.AsMap())
+}
+
+// Equal reports whether v and other hold the same values, ignoring
+// differences in representation that YAML and JSON round-trips introduce.
+//
+// Numbers are compared by value, so an int 25 equals a float64 25.0. Values
+// equals the equivalent map[string]interface{}, and a nil
+// map[string]interface{} or []interface{} equals an empty one. A key set to
+// null, an untyped nil, is treated as absent, so it does not equal an empty
+// table or list.
+func (v Values) Equal(other Values) bool {
+	return equalValues(map[string]interface{}(v), map[string]interface{}(other))
+}
+
+func equalValues(a, b interface{}) bool {
+	if t, ok := a.(Values); ok {
+		a = map[string]interface{}(t)
+	}
+	if t, ok := b.(Values); ok {
+		b = map[string]interface{}(t)
+	}
+	switch at := a.(type) {
+	case map[string]interface{}:
+		bt, ok := b.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, av := range at {
+			if !equalValues(av, bt[k]) {
+				return false
+			}
+		}
+		for k, bv := range bt {
+			if _, ok := at[k]; !ok && bv != nil {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bt, ok := b.([]interface{})
+		if !ok || len(at) != len(bt) {
+			return false
+		}
+		for i := range at {
+			if !equalValues(at[i], bt[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if af, ok := toFloat(a); ok {
+		bf, ok := toFloat(b)
+		return ok && af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func subtractTables(vals, base map[string]interface{}) map[string]interface{} {
+	out := map[string]interface{}{}
+	for k, val := range vals {
+		bv, ok := base[k]
+		if t, isTable := val.(map[string]interface{}); isTable {
+			bt, _ := bv.(map[string]interface{})
+			if sub := subtractTables(t, bt); len(sub) > 0 {
+				out[k] = sub
+			}
+			continue
+		}
+		if !ok || !reflect.DeepEqual(val, bv) {
+			out[k] = deepCopyValue(val)
+		}
+	}
+	return out
+}
+
+func diffTables(prefix string, oldVals, newVals map[string]interface{}, opts DiffOptions, changes *[]Change) {
+	keys := make([]string, 0, len(oldVals)+len(newVals))
+	for k := range oldVals {
+		keys = append(keys, k)
+	}
+	for k := range newVals {
+		if _, ok := oldVals[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := joinPath(prefix, k)
+		if opts.ignored(p) {
+			continue
+		}
+		ov, inOld := oldVals[k]
+		nv, inNew := newVals[k]
+		switch {
+		case !inNew:
+			*changes = append(*changes, Change{Path: p, Kind: ChangeRemoved, Old: ov})
+		case !inOld:
+			*changes = append(*changes, Change{Path: p, Kind: ChangeAdded, New: nv})
+		case istable(ov) && istable(nv):
+			diffTables(p, ov.(map[string]interface{}), nv.(map[string]interface{}), opts, changes)
+		case !reflect.DeepEqual(ov, nv):
+			*changes = append(*changes, Change{Path: p, Kind: ChangeModified, Old: ov, New: nv})
+		}
+	}
+}
+
+// ignored reports whether the given path falls under one of the ignored paths.
+func (o DiffOptions) ignored(p string) bool {
+	segments := strings.Split(p, ".")
+	for _, ignore := range o.IgnorePaths {
+		if matchPathPrefix(strings.Split(ignore, "."), segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPathPrefix reports whether the pattern segments match the leading
+// segments of a path.
+func matchPathPrefix(pattern, segments []string) bool {
+	if len(pattern) > len(segments) {
+		return false
+	}
+	for i, pat := range pattern {
+		if pat == segments[i] {
+			continue
+		}
+		if ok, err := path.Match(pat, segments[i]); err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
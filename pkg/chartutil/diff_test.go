@@ -0,0 +1,229 @@
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/helm/pkg/proto/hapi/chart"
+)
+
+func TestDiff(t *testing.T) {
+	oldVals, err := ReadValues([]byte(`
+name: Ishmael
+ship:
+  name: Pequod
+  captain: Ahab
+database:
+  password: s3cr3t
+cache:
+  password: hunter2
+deployedAt: "1851-10-18"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newVals, err := ReadValues([]byte(`
+name: Ishmael
+ship:
+  name: Rachel
+  crew: 30
+database:
+  password: n3ws3cr3t
+cache:
+  password: hunter3
+deployedAt: "1851-11-14"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []Change{
+		{Path: "cache.password", Kind: ChangeModified, Old: "hunter2", New: "hunter3"},
+		{Path: "database.password", Kind: ChangeModified, Old: "s3cr3t", New: "n3ws3cr3t"},
+		{Path: "deployedAt", Kind: ChangeModified, Old: "1851-10-18", New: "1851-11-14"},
+		{Path: "ship.captain", Kind: ChangeRemoved, Old: "Ahab"},
+		{Path: "ship.crew", Kind: ChangeAdded, New: float64(30)},
+		{Path: "ship.name", Kind: ChangeModified, Old: "Pequod", New: "Rachel"},
+	}
+	if got := Diff(oldVals, newVals, DiffOptions{}); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expected %v, got %v", expect, got)
+	}
+
+	opts := DiffOptions{IgnorePaths: []string{"*.password", "deployedAt", "ship.name"}}
+	expect = []Change{
+		{Path: "ship.captain", Kind: ChangeRemoved, Old: "Ahab"},
+		{Path: "ship.crew", Kind: ChangeAdded, New: float64(30)},
+	}
+	if got := Diff(oldVals, newVals, opts); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expected %v, got %v", expect, got)
+	}
+
+	// An ignored table ignores everything below it.
+	opts = DiffOptions{IgnorePaths: []string{"ship", "database", "cache", "deployedAt"}}
+	if got := Diff(oldVals, newVals, opts); len(got) != 0 {
+		t.Errorf("Expected no changes, got %v", got)
+	}
+}
+
+func TestValuesDiff(t *testing.T) {
+	base, err := ReadValuesFile("testdata/moby/values.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	override, err := ReadValues([]byte(testCoalesceValuesYaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := Values(CoalesceTables(override, base.DeepCopy()))
+
+	changes := base.Diff(merged)
+	expect := []Change{
+		{Path: "back", Kind: ChangeModified, Old: "exists", New: ""},
+		{Path: "bottom", Kind: ChangeModified, Old: "exists"},
+		{Path: "front", Kind: ChangeModified, Old: "exists"},
+		{Path: "global", Kind: ChangeAdded, New: override["global"]},
+		{Path: "left", Kind: ChangeModified, Old: "exists"},
+		{Path: "pequod", Kind: ChangeAdded, New: override["pequod"]},
+		{Path: "right", Kind: ChangeModified, Old: "exists"},
+		{Path: "top", Kind: ChangeModified, Old: "nope", New: "yup"},
+	}
+	if !reflect.DeepEqual(changes, expect) {
+		t.Errorf("Expected %v, got %v", expect, changes)
+	}
+
+	// A scalar that becomes a table is a single modification.
+	table := map[string]interface{}{"name": "Pequod"}
+	changes = Values{"ship": "Pequod"}.Diff(Values{"ship": table})
+	expect = []Change{{Path: "ship", Kind: ChangeModified, Old: "Pequod", New: table}}
+	if !reflect.DeepEqual(changes, expect) {
+		t.Errorf("Expected %v, got %v", expect, changes)
+	}
+
+	if changes := base.Diff(base.DeepCopy()); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %v", changes)
+	}
+}
+
+func TestValuesSubtract(t *testing.T) {
+	chartValues := `
+name: al Rashid
+where:
+  city: Basrah
+  title: caliph
+`
+	overrideValues := `
+name: Haroun
+where:
+  city: Baghdad
+  date: 809 CE
+`
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "test"},
+		Values:   &chart.Config{Raw: chartValues},
+		Dependencies: []*chart.Chart{
+			{
+				Metadata: &chart.Metadata{Name: "where"},
+				Values:   &chart.Config{Raw: ""},
+			},
+		},
+	}
+
+	coalesced, err := CoalesceValues(c, &chart.Config{Raw: overrideValues})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults, err := ReadValues([]byte(chartValues))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, err := ReadValues([]byte(overrideValues))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := coalesced.Subtract(defaults); !reflect.DeepEqual(got, expect) {
+		t.Errorf("Expected %v, got %v", expect, got)
+	}
+
+	// A table that replaces a scalar, or a changed list, is kept whole.
+	v := Values{
+		"ship":  map[string]interface{}{"name": "Pequod"},
+		"crew":  []interface{}{"Ahab", "Starbuck"},
+		"whale": "white",
+	}
+	base := Values{
+		"ship":  "Pequod",
+		"crew":  []interface{}{"Ahab"},
+		"whale": "white",
+	}
+	expect = Values{
+		"ship": map[string]interface{}{"name": "Pequod"},
+		"crew": []interface{}{"Ahab", "Starbuck"},
+	}
+	if got := v.Subtract(base); !reflect.DeepEqual(got, expect) {
+		t.Errorf("Expected %v, got %v", expect, got)
+	}
+
+	if got := v.Subtract(v); len(got) != 0 {
+		t.Errorf("Expected nothing left, got %v", got)
+	}
+}
+
+func TestValuesEqual(t *testing.T) {
+	a := Values{
+		"age":  25,
+		"ship": map[string]interface{}{"name": "Pequod", "tons": 273},
+		"crew": []interface{}{"Ahab", map[string]interface{}{"rank": 1}},
+		"mate": nil,
+		"logs": []interface{}{},
+	}
+	b := Values{
+		"age":  25.0,
+		"ship": Values{"name": "Pequod", "tons": float64(273)},
+		"crew": []interface{}{"Ahab", map[string]interface{}{"rank": int64(1)}},
+		"logs": []interface{}(nil),
+	}
+	if !a.Equal(b) || !b.Equal(a) {
+		t.Errorf("Expected %v and %v to be equal", a, b)
+	}
+	if n, e := (Values{"mate": map[string]interface{}(nil)}), (Values{"mate": Values{}}); !n.Equal(e) || !e.Equal(n) {
+		t.Errorf("Expected a nil table to equal an empty one")
+	}
+
+	// A null is an absent key, not an empty table or list.
+	for _, empty := range []interface{}{map[string]interface{}{}, []interface{}{}} {
+		n, e := Values{"mate": nil}, Values{"mate": empty}
+		if n.Equal(e) || e.Equal(n) {
+			t.Errorf("Expected a null to differ from %v", empty)
+		}
+	}
+
+	tests := []Values{
+		{"age": 26, "ship": a["ship"], "crew": a["crew"]},
+		{"age": "25", "ship": a["ship"], "crew": a["crew"]},
+		{"age": 25, "ship": map[string]interface{}{"name": "Pequod"}, "crew": a["crew"]},
+		{"age": 25, "ship": a["ship"], "crew": []interface{}{"Ahab"}},
+		{"age": 25, "ship": a["ship"], "crew": a["crew"], "whale": "white"},
+	}
+	for _, tt := range tests {
+		if a.Equal(tt) || tt.Equal(a) {
+			t.Errorf("Expected %v and %v to differ", a, tt)
+		}
+	}
+}
@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"path"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/ghodss/yaml"
 
@@ -47,14 +48,25 @@ func NewFiles(from []*any.Any) Files {
 // The returned data is raw. In a template context, this is identical to calling
 // {{index .Files $path}}.
 //
+// Given more than one path, GetBytes returns the contents of the files joined
+// in order:
+//
+//	{{.Files.GetBytes "header.txt" "body.txt"}}
+//
 // This is intended to be accessed from within a template, so a missed key returns
-// an empty []byte.
-func (f Files) GetBytes(name string) []byte {
-	v, ok := f[name]
-	if !ok {
+// an empty []byte, and is skipped when joining files.
+func (f Files) GetBytes(names ...string) []byte {
+	if len(names) == 1 {
+		if v, ok := f[names[0]]; ok {
+			return v
+		}
 		return []byte{}
 	}
-	return v
+	b := []byte{}
+	for _, name := range names {
+		b = append(b, f[name]...)
+	}
+	return b
 }
 
 // Get returns a string representation of the given file.
@@ -67,6 +79,22 @@ func (f Files) Get(name string) string {
 	return string(f.GetBytes(name))
 }
 
+// Lookup gets a file by path and reports whether it exists.
+//
+// Unlike GetBytes, this tells a missing file apart from an empty one: an
+// empty file returns an empty []byte and true, while a missing file returns
+// nil and false.
+func (f Files) Lookup(name string) ([]byte, bool) {
+	v, ok := f[name]
+	if !ok {
+		return nil, false
+	}
+	if v == nil {
+		v = []byte{}
+	}
+	return v, true
+}
+
 // Glob takes a glob pattern and returns another files object only containing
 // matched  files.
 //
@@ -91,10 +119,63 @@ func (f Files) Glob(pattern string) Files {
 	return nf
 }
 
+// Scoped returns another files object only containing the files under the
+// given directory, with the directory stripped from their names.
+//
+// A trailing slash on prefix is optional, so "charts/foo" and "charts/foo/"
+// both scope to the files in charts/foo. An empty prefix returns all files.
+func (f Files) Scoped(prefix string) Files {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	nf := NewFiles(nil)
+	for name, contents := range f {
+		if strings.HasPrefix(name, prefix) && len(name) > len(prefix) {
+			nf[strings.TrimPrefix(name, prefix)] = contents
+		}
+	}
+	return nf
+}
+
+// TextFiles returns another files object only containing the files that look
+// like text.
+//
+// A file is considered text if it is valid UTF-8 and contains no NUL bytes.
+// This is useful for keeping binary files out of a ConfigMap:
+//
+//   data:
+// {{ (.Files.Glob "config/**").TextFiles.AsConfig | indent 4 }}
+func (f Files) TextFiles() Files {
+	nf := NewFiles(nil)
+	for name, contents := range f {
+		if isText(contents) {
+			nf[name] = contents
+		}
+	}
+	return nf
+}
+
+// BinaryFiles returns another files object only containing the files that do
+// not look like text. It is the complement of TextFiles.
+func (f Files) BinaryFiles() Files {
+	nf := NewFiles(nil)
+	for name, contents := range f {
+		if !isText(contents) {
+			nf[name] = contents
+		}
+	}
+	return nf
+}
+
+// isText reports whether b is valid UTF-8 without any NUL bytes.
+func isText(b []byte) bool {
+	return utf8.Valid(b) && bytes.IndexByte(b, 0) == -1
+}
+
 // AsConfig turns a Files group and flattens it to a YAML map suitable for
 // including in the 'data' section of a Kubernetes ConfigMap definition.
 // Duplicate keys will be overwritten, so be aware that your file names
-// (regardless of path) should be unique.
+// (regardless of path) should be unique. Keys are sorted by file name.
 //
 // This is designed to be called from a template, and will return empty string
 // (via ToYaml function) if it cannot be serialized to YAML, or if the Files
@@ -123,7 +204,7 @@ func (f Files) AsConfig() string {
 // AsSecrets returns the base64-encoded value of a Files object suitable for
 // including in the 'data' section of a Kubernetes Secret definition.
 // Duplicate keys will be overwritten, so be aware that your file names
-// (regardless of path) should be unique.
+// (regardless of path) should be unique. Keys are sorted by file name.
 //
 // This is designed to be called from a template, and will return empty string
 // (via ToYaml function) if it cannot be serialized to YAML, or if the Files
@@ -149,7 +230,8 @@ func (f Files) AsSecrets() string {
 }
 
 // Lines returns each line of a named file (split by "\n") as a slice, so it can
-// be ranged over in your templates.
+// be ranged over in your templates. A final newline does not produce an extra
+// empty line.
 //
 // This is designed to be called from a template.
 //
@@ -160,7 +242,11 @@ func (f Files) Lines(path string) []string {
 		return []string{}
 	}
 
-	return strings.Split(string(f[path]), "\n")
+	s := strings.TrimSuffix(string(f[path]), "\n")
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, "\n")
 }
 
 // ToYaml takes an interface, marshals it to yaml, and returns a string. It will
@@ -56,6 +56,38 @@ func TestNewFiles(t *testing.T) {
 	}
 }
 
+func TestFileLookup(t *testing.T) {
+	as := assert.New(t)
+
+	f := NewFiles(getTestFiles())
+	f["ship/empty.txt"] = nil
+
+	data, ok := f.Lookup("ship/captain.txt")
+	as.True(ok)
+	as.Equal([]byte("The Captain"), data)
+
+	data, ok = f.Lookup("ship/empty.txt")
+	as.True(ok)
+	as.Equal([]byte{}, data)
+
+	data, ok = f.Lookup("ship/missing.txt")
+	as.False(ok)
+	as.Nil(data)
+
+	as.Equal([]byte{}, f.GetBytes("ship/missing.txt"))
+}
+
+func TestFileGetBytesMultiple(t *testing.T) {
+	as := assert.New(t)
+
+	f := NewFiles(getTestFiles())
+
+	as.Equal([]byte("The CaptainLegatt"), f.GetBytes("ship/captain.txt", "ship/stowaway.txt"))
+	as.Equal([]byte("The CaptainLegatt"), f.GetBytes("ship/captain.txt", "ship/missing.txt", "ship/stowaway.txt"))
+	as.Equal([]byte{}, f.GetBytes("ship/missing.txt", "cargo/missing.txt"))
+	as.Equal([]byte{}, f.GetBytes())
+}
+
 func TestFileGlob(t *testing.T) {
 	as := assert.New(t)
 
@@ -65,6 +97,54 @@ func TestFileGlob(t *testing.T) {
 
 	as.Len(matched, 2, "Should be two files in glob story/**")
 	as.Equal("Joseph Conrad", matched.Get("story/author.txt"))
+
+	matched = f.Glob("multiline/*")
+	as.Len(matched, 1, "Should be one file in glob multiline/*")
+	as.Equal("bar\nfoo", matched.Get("multiline/test.txt"))
+
+	matched = f.Glob("*.txt")
+	as.Empty(matched, "A single star should not match across directories")
+
+	matched = f.Glob("cargo/*")
+	as.NotNil(matched)
+	as.Empty(matched, "Should be no files in glob cargo/*")
+}
+
+func TestFileScoped(t *testing.T) {
+	as := assert.New(t)
+
+	f := NewFiles(getTestFiles())
+	f["scheherazade/shahryar.txt"] = []byte("The King")
+	f["scheherazade/tales/sinbad.txt"] = []byte("The Sailor")
+	f["scheherazadeh.txt"] = []byte("Not a tale")
+
+	scoped := f.Scoped("scheherazade/")
+	as.Len(scoped, 2)
+	as.Equal("The King", scoped.Get("shahryar.txt"))
+	as.Equal("The Sailor", scoped.Get("tales/sinbad.txt"))
+	as.NotContains(scoped, "ship/captain.txt")
+	as.NotContains(scoped, "h.txt")
+
+	as.Equal(scoped, f.Scoped("scheherazade"))
+	as.Equal(f, f.Scoped(""))
+	as.Empty(f.Scoped("cargo"))
+}
+
+func TestTextAndBinaryFiles(t *testing.T) {
+	as := assert.New(t)
+
+	f := NewFiles(getTestFiles())
+	f["ship/logo.png"] = []byte{0x89, 'P', 'N', 'G', 0x00, 0x1a}
+	f["ship/latin1.txt"] = []byte{'c', 'a', 'f', 0xe9}
+
+	text := f.TextFiles()
+	as.Len(text, len(cases))
+	as.Equal("The Captain", text.Get("ship/captain.txt"))
+
+	binary := f.BinaryFiles()
+	as.Len(binary, 2)
+	as.Contains(binary, "ship/logo.png")
+	as.Contains(binary, "ship/latin1.txt")
 }
 
 func TestToConfig(t *testing.T) {
@@ -76,6 +156,12 @@ func TestToConfig(t *testing.T) {
 
 	out = f.Glob("ship/**").AsConfig()
 	as.Equal("captain.txt: The Captain\nstowaway.txt: Legatt\n", out)
+
+	out = f.Glob("multiline/*").AsConfig()
+	as.Equal("test.txt: |-\n  bar\n  foo\n", out)
+
+	var nilFiles Files
+	as.Equal("", nilFiles.AsConfig())
 }
 
 func TestToSecret(t *testing.T) {
@@ -85,6 +171,12 @@ func TestToSecret(t *testing.T) {
 
 	out := f.Glob("ship/**").AsSecrets()
 	as.Equal("captain.txt: VGhlIENhcHRhaW4=\nstowaway.txt: TGVnYXR0\n", out)
+
+	out = f.Glob("multiline/*").AsSecrets()
+	as.Equal("test.txt: YmFyCmZvbw==\n", out)
+
+	var nilFiles Files
+	as.Equal("", nilFiles.AsSecrets())
 }
 
 func TestLines(t *testing.T) {
@@ -96,6 +188,14 @@ func TestLines(t *testing.T) {
 	as.Len(out, 2)
 
 	as.Equal("bar", out[0])
+
+	f["multiline/newline.txt"] = []byte("bar\n\nfoo\n")
+	as.Equal([]string{"bar", "", "foo"}, f.Lines("multiline/newline.txt"))
+
+	f["multiline/empty.txt"] = []byte("\n")
+	as.Equal([]string{}, f.Lines("multiline/empty.txt"))
+
+	as.Equal([]string{}, f.Lines("multiline/missing.txt"))
 }
 
 func TestToYaml(t *testing.T) {
@@ -155,7 +155,7 @@ func ProcessRequirementsConditions(reqs *Requirements, cvals Values) {
 						} else {
 							log.Printf("Warning: Condition path '%s' for chart %s returned non-bool value", c, r.Name)
 						}
-					} else if _, ok := err.(ErrNoValue); !ok {
+					} else if _, ok := err.(*PathError); !ok {
 						// this is a real error
 						log.Printf("Warning: PathValue returned error %v", err)
 
@@ -17,24 +17,154 @@ limitations under the License.
 package chartutil
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
+	"math"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/BurntSushi/toml"
 	"github.com/ghodss/yaml"
 	"github.com/golang/protobuf/ptypes/timestamp"
+	goyaml "gopkg.in/yaml.v2"
 	"k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/strvals"
 )
 
+// ErrEmptyPath indicates that a zero length path was given to Values.
+var ErrEmptyPath = errors.New("YAML path string cannot be zero length")
+
 // ErrNoTable indicates that a chart does not have a matching table.
 type ErrNoTable error
 
 // ErrNoValue indicates that Values does not contain a key with a value
 type ErrNoValue error
 
+// missingTable and missingValue are the ErrNoTable and ErrNoValue errors for
+// keys that do not exist. ErrNoTable and ErrNoValue are satisfied by any
+// error, so these let IsNoTable and IsNoValue tell the two apart.
+type missingTable string
+
+func (e missingTable) Error() string { return string(e) }
+
+type missingValue string
+
+func (e missingValue) Error() string { return string(e) }
+
+// IsNoTable reports whether err, or the error in a *PathError, is an
+// ErrNoTable for a table that does not exist.
+func IsNoTable(err error) bool {
+	_, ok := pathCause(err).(missingTable)
+	return ok
+}
+
+// IsNoValue reports whether err, or the error in a *PathError, is an
+// ErrNoValue for a value that does not exist.
+func IsNoValue(err error) bool {
+	_, ok := pathCause(err).(missingValue)
+	return ok
+}
+
+// pathCause returns the error in a *PathError, or err if it is not one.
+func pathCause(err error) error {
+	if pe, ok := err.(*PathError); ok {
+		return pe.Err
+	}
+	return err
+}
+
+// ErrNotTable indicates that a key exists in Values, but its value is not a
+// table, so a path cannot continue through it.
+//
+// It is also an ErrNoTable, as a value that is not a table was before.
+type ErrNotTable struct {
+	// Key is the name of the value that is not a table.
+	Key string
+}
+
+func (e ErrNotTable) Error() string {
+	return fmt.Sprintf("no table named %q", e.Key)
+}
+
+// DefaultMaxDepth is the default limit on how deeply tables, lists and
+// subcharts are nested before coalescing gives up.
+//
+// Only coalescing is limited. CoalesceValues and CoalesceTablesWithOptions
+// return an ErrMaxDepth. CoalesceTables and the functions built on it have no
+// error to return, so they leave out the values nested too deeply and log a
+// warning. Path lookups such as PathValue step through one table per path
+// segment, so the path bounds them. Other walks of Values, such as DeepCopy
+// and Equal, have no limit.
+const DefaultMaxDepth = 1000
+
+// ErrMaxDepth indicates that values or charts are nested more deeply than
+// coalescing allows.
+type ErrMaxDepth struct {
+	// Depth is the maximum depth that was exceeded.
+	Depth int
+}
+
+func (e ErrMaxDepth) Error() string {
+	return fmt.Sprintf("values are nested more than %d levels deep", e.Depth)
+}
+
+// ErrWrongType indicates that a value exists, but does not have the type that
+// was asked for.
+type ErrWrongType struct {
+	// Path is the path of the value.
+	Path string
+	// Type is the type that was asked for.
+	Type string
+	// Value is the value found at the path.
+	Value interface{}
+}
+
+func (e ErrWrongType) Error() string {
+	return fmt.Sprintf("%s is not a %s: %v (%T)", e.Path, e.Type, e.Value, e.Value)
+}
+
+// ErrTableMismatch indicates that two sets of values cannot be coalesced
+// because a key holds a table in one and a non-table value in the other.
+type ErrTableMismatch struct {
+	// Paths are the dotted paths of the mismatched keys, sorted.
+	Paths []string
+}
+
+func (e ErrTableMismatch) Error() string {
+	return fmt.Sprintf("cannot coalesce a table with a non-table value at: %s", strings.Join(e.Paths, ", "))
+}
+
+// PathError records a failure to resolve a path through Values.
+//
+// Err is ErrEmptyPath, an ErrNotTable, or an ErrNoTable or ErrNoValue for a
+// missing key, which IsNoTable and IsNoValue report.
+type PathError struct {
+	// Path is the full path that was being resolved.
+	Path string
+	// Segment is the part of the path that could not be resolved.
+	Segment string
+	// Err is the reason the segment could not be resolved.
+	Err error
+}
+
+func (e *PathError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
 // GlobalKey is the name of the Values key that is used for storing global vars.
 const GlobalKey = "global"
 
@@ -42,9 +172,77 @@ const GlobalKey = "global"
 type Values map[string]interface{}
 
 // YAML encodes the Values into a YAML string.
+//
+// Keys are sorted, so the same Values always produce the same output, and the
+// output can be read back with ReadValues.
 func (v Values) YAML() (string, error) {
-	b, err := yaml.Marshal(v)
-	return string(b), err
+	var b bytes.Buffer
+	err := v.Encode(&b)
+	return b.String(), err
+}
+
+// JSON encodes the Values into JSON.
+//
+// Keys are sorted, so the same Values always produce the same output. Tables
+// decoded by a YAML library as map[interface{}]interface{} are converted to
+// map[string]interface{} first, as encoding/json does not accept them.
+func (v Values) JSON() ([]byte, error) {
+	norm, err := normalizeMaps(map[string]interface{}(v.AsMap()))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(norm)
+}
+
+// normalizeMaps returns a copy of v where every map[interface{}]interface{}
+// has been converted to a map[string]interface{}, all the way down.
+//
+// Keys that are strings, booleans or numbers are converted to their string
+// form. Any other key is an error.
+func normalizeMaps(v interface{}) (interface{}, error) {
+	switch vv := v.(type) {
+	case Values:
+		return normalizeMaps(map[string]interface{}(vv))
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(vv))
+		for k, val := range vv {
+			nv, err := normalizeMaps(val)
+			if err != nil {
+				return nil, err
+			}
+			m[k] = nv
+		}
+		return m, nil
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(vv))
+		for k, val := range vv {
+			var key string
+			switch k.(type) {
+			case string, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+				key = fmt.Sprint(k)
+			default:
+				return nil, fmt.Errorf("cannot use %v (%T) as a key", k, k)
+			}
+			nv, err := normalizeMaps(val)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = nv
+		}
+		return m, nil
+	case []interface{}:
+		l := make([]interface{}, len(vv))
+		for i, val := range vv {
+			nv, err := normalizeMaps(val)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = nv
+		}
+		return l, nil
+	default:
+		return v, nil
+	}
 }
 
 // Table gets a table (YAML subsection) from a Values object.
@@ -58,7 +256,8 @@ func (v Values) YAML() (string, error) {
 // The above will be evaluated as "The table bar inside the table
 // foo".
 //
-// An ErrNoTable is returned if the table does not exist.
+// A *PathError wrapping an ErrNoTable is returned if the table does not exist,
+// or one wrapping an ErrNotTable if part of the name is not a table.
 func (v Values) Table(name string) (Values, error) {
 	names := strings.Split(name, ".")
 	table := v
@@ -67,12 +266,27 @@ func (v Values) Table(name string) (Values, error) {
 	for _, n := range names {
 		table, err = tableLookup(table, n)
 		if err != nil {
-			return table, err
+			return table, &PathError{Path: name, Segment: n, Err: err}
 		}
 	}
 	return table, err
 }
 
+// Keys returns the sorted keys of the table at the given path, or of the
+// top-level table if the path is empty.
+//
+// As with Table, a *PathError is returned if the path does not name a table.
+func (v Values) Keys(name string) ([]string, error) {
+	table := v
+	if name != "" {
+		var err error
+		if table, err = v.Table(name); err != nil {
+			return nil, err
+		}
+	}
+	return sortedKeys(table), nil
+}
+
 // AsMap is a utility function for converting Values to a map[string]interface{}.
 //
 // It protects against nil map panics.
@@ -83,9 +297,48 @@ func (v Values) AsMap() map[string]interface{} {
 	return v
 }
 
+// DeepCopy returns a copy of the Values that shares no tables or lists with
+// the original.
+//
+// Coalescing modifies its destination in place, so copy a set of values
+// before coalescing into it if it is used more than once.
+func (v Values) DeepCopy() Values {
+	if v == nil {
+		return nil
+	}
+	return deepCopyMap(v)
+}
+
+func deepCopyMap(src map[string]interface{}) map[string]interface{} {
+	dest := make(map[string]interface{}, len(src))
+	for k, v := range src {
+		dest[k] = deepCopyValue(v)
+	}
+	return dest
+}
+
+func deepCopyValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case Values:
+		return v.DeepCopy()
+	case map[string]interface{}:
+		return deepCopyMap(v)
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = deepCopyValue(e)
+		}
+		return l
+	default:
+		return v
+	}
+}
+
 // Encode writes serialized Values information to the given io.Writer.
+//
+// The values are marshalled with the same YAML library as ReadValues, which
+// goes through JSON, so the output is the same as the YAML method's.
 func (v Values) Encode(w io.Writer) error {
-	//return yaml.NewEncoder(w).Encode(v)
 	out, err := yaml.Marshal(v)
 	if err != nil {
 		return err
@@ -110,10 +363,449 @@ func (v Values) MergeInto(src Values) {
 	}
 }
 
+// Merge returns a new set of values with overrides merged onto v.
+//
+// The values in overrides win: they replace the values in v, and nested
+// tables are merged key by key, as with MergeInto. Lists are not merged; a
+// list in overrides replaces the list in v. An override may change the type
+// of a value, such as a table replaced by a string, and a key set to null in
+// overrides is kept with a nil value. Neither v nor overrides is modified.
+func (v Values) Merge(overrides Values) Values {
+	out := v.DeepCopy()
+	if out == nil {
+		out = Values{}
+	}
+	out.MergeInto(overrides.DeepCopy())
+	return out
+}
+
+// MapLeaves replaces every leaf value in v, in place, with the result of
+// calling fn with the leaf's path and value.
+//
+// Paths are dotted keys with list indices in brackets, such as
+// "secrets.password" or "hosts[0]". Tables and lists themselves are not
+// passed to fn, but the values inside them are, so the structure of v is
+// kept. Leaves are visited in sorted key order.
+func (v Values) MapLeaves(fn func(path string, value interface{}) interface{}) {
+	mapLeaves("", map[string]interface{}(v), fn)
+}
+
+func mapLeaves(path string, v interface{}, fn func(path string, value interface{}) interface{}) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(vv) {
+			vv[k] = mapLeaves(joinPath(path, k), vv[k], fn)
+		}
+		return vv
+	case Values:
+		mapLeaves(path, map[string]interface{}(vv), fn)
+		return vv
+	case []interface{}:
+		for i, val := range vv {
+			vv[i] = mapLeaves(fmt.Sprintf("%s[%d]", path, i), val, fn)
+		}
+		return vv
+	default:
+		return fn(path, v)
+	}
+}
+
+// CheckMaxStringLength returns a description of every string value in v that
+// is longer than max bytes, along with its path and length.
+//
+// This is a policy check that applies to every string in the values, and is
+// meant to catch large blobs (such as inlined files) that do not belong in
+// values.
+func (v Values) CheckMaxStringLength(max int) []string {
+	var violations []string
+	walkLeaves("", v.AsMap(), func(path string, val interface{}) {
+		if s, ok := val.(string); ok && len(s) > max {
+			violations = append(violations, fmt.Sprintf("%s: length %d exceeds the maximum of %d", path, len(s), max))
+		}
+	})
+	return violations
+}
+
+// Gate pairs a boolean value with the values that it enables.
+type Gate struct {
+	// Path is the dotted path of the boolean value, such as "tls.enabled".
+	Path string
+	// Subtree is the dotted path of the values guarded by the boolean, such
+	// as "tls". It may be the table that holds the boolean itself.
+	Subtree string
+}
+
+// CheckGatedConsistency checks that values guarded by a boolean agree with it.
+//
+// For each gate, it reports the subtree if it is populated while the boolean
+// is false (or missing), or if it is empty while the boolean is true. The
+// boolean itself does not count towards populating the subtree. A gate whose
+// path is not a boolean is reported as well.
+//
+// Each finding is a path followed by a message, suitable for a linter.
+func (v Values) CheckGatedConsistency(gates []Gate) []string {
+	var findings []string
+	for _, g := range gates {
+		enabled := false
+		if val, ok := v.lookup(g.Path); ok && val != nil {
+			b, ok := val.(bool)
+			if !ok {
+				findings = append(findings, fmt.Sprintf("%s: gate is not a boolean: %v", g.Path, val))
+				continue
+			}
+			enabled = b
+		}
+
+		populated := false
+		if sub, ok := v.lookup(g.Subtree); ok {
+			walkLeaves(g.Subtree, sub, func(path string, val interface{}) {
+				if path != g.Path && !isEmptyValue(val) {
+					populated = true
+				}
+			})
+		}
+
+		switch {
+		case populated && !enabled:
+			findings = append(findings, fmt.Sprintf("%s: values are set, but %s is not true", g.Subtree, g.Path))
+		case !populated && enabled:
+			findings = append(findings, fmt.Sprintf("%s: no values are set, but %s is true", g.Subtree, g.Path))
+		}
+	}
+	return findings
+}
+
+// Dig returns the value found by following the given keys through nested
+// tables, and whether it was found. The value may itself be a table.
+//
+// Each key is used as is, so a key may contain dots:
+//
+//	v.Dig("annotations", "example.com/owner")
+//
+// With no keys, the Values themselves are returned.
+func (v Values) Dig(keys ...string) (interface{}, bool) {
+	var cur interface{} = v
+	for _, k := range keys {
+		var t map[string]interface{}
+		switch vv := cur.(type) {
+		case map[string]interface{}:
+			t = vv
+		case Values:
+			t = vv
+		default:
+			return nil, false
+		}
+		var ok bool
+		if cur, ok = t[k]; !ok {
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// lookup returns the value at the end of a dotted path. Unlike PathValue, the
+// value may be a table.
+func (v Values) lookup(ypath string) (interface{}, bool) {
+	return v.Dig(strings.Split(ypath, ".")...)
+}
+
+// isEmptyValue reports whether v is null or an empty string.
+func isEmptyValue(v interface{}) bool {
+	return v == nil || v == ""
+}
+
+// walkLeaves calls fn on every leaf under v, in sorted key order.
+//
+// A leaf is any value that is not a table or a list. The path given to fn is
+// made of dot-separated keys, with list indices in brackets:
+//
+//	chapter.one.title
+//	stanza[0]
+func walkLeaves(path string, v interface{}, fn func(path string, val interface{})) {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(vv) {
+			walkLeaves(joinPath(path, k), vv[k], fn)
+		}
+	case Values:
+		walkLeaves(path, map[string]interface{}(vv), fn)
+	case []interface{}:
+		for i, val := range vv {
+			walkLeaves(fmt.Sprintf("%s[%d]", path, i), val, fn)
+		}
+	default:
+		fn(path, v)
+	}
+}
+
+// joinPath appends key to a dotted path.
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// sortedKeys returns the keys of m in sorted order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ParseSet parses a set line, as given to the --set flag, into Values.
+//
+// A set line is of the form name1=value1,name2=value2. Values that look like
+// integers, booleans or null are typed accordingly. See the strvals package
+// for the full syntax.
+func ParseSet(s string) (Values, error) {
+	return strvals.Parse(s)
+}
+
+// ApplySet parses a set line, as given to the --set flag, and merges the result
+// into the Values. Keys in the set line overwrite those already present.
+func (v Values) ApplySet(s string) error {
+	return strvals.ParseInto(s, v)
+}
+
+// ApplySetString is like ApplySet, as for the --set-string flag: every value
+// in the set line is kept as a string, whatever it looks like.
+func (v Values) ApplySetString(s string) error {
+	return strvals.ParseIntoString(s, v)
+}
+
+// Flatten returns a flat map from the path of every leaf value to the value.
+//
+// Paths are made of dot-separated keys, with list indices in brackets, as in
+// the --set flag:
+//
+//	chapter.one.title
+//	stanza[0]
+//
+// Keys are escaped as in the --set flag too: a '.', '[', ',', '=' or '\' in a
+// key is preceded by a backslash. Empty tables and lists are kept as leaves,
+// so that Unflatten can restore them.
+func (v Values) Flatten() map[string]interface{} {
+	flat := map[string]interface{}{}
+	flattenInto("", v.AsMap(), flat)
+	return flat
+}
+
+// Paths returns the sorted path of every leaf value, in the form that Flatten
+// uses for its keys.
+func (v Values) Paths() []string {
+	flat := v.Flatten()
+	paths := make([]string, 0, len(flat))
+	for p := range flat {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func flattenInto(path string, v interface{}, flat map[string]interface{}) {
+	switch vv := v.(type) {
+	case Values:
+		flattenInto(path, map[string]interface{}(vv), flat)
+	case map[string]interface{}:
+		if len(vv) == 0 && path != "" {
+			flat[path] = map[string]interface{}{}
+			return
+		}
+		for k, val := range vv {
+			flattenInto(joinPath(path, escapeKey(k)), val, flat)
+		}
+	case []interface{}:
+		if len(vv) == 0 {
+			flat[path] = []interface{}{}
+			return
+		}
+		for i, val := range vv {
+			flattenInto(fmt.Sprintf("%s[%d]", path, i), val, flat)
+		}
+	default:
+		flat[path] = v
+	}
+}
+
+// Unflatten builds a tree of tables and lists from a flat map of paths to
+// values, as returned by Flatten. It is the inverse of Flatten.
+//
+// Missing list elements are set to nil. An error is returned if a path is
+// malformed, or if two paths conflict, such as "a" and "a.b".
+func Unflatten(flat map[string]interface{}) (Values, error) {
+	paths := make([]string, 0, len(flat))
+	for p := range flat {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var root interface{} = map[string]interface{}{}
+	for _, p := range paths {
+		segs, prefixes, err := parseFlatPath(p)
+		if err != nil {
+			return Values{}, err
+		}
+		for _, prefix := range prefixes {
+			if _, ok := flat[prefix]; ok {
+				return Values{}, fmt.Errorf("path %q conflicts with path %q", p, prefix)
+			}
+		}
+		if root, err = unflattenSet(root, segs, deepCopyValue(flat[p])); err != nil {
+			return Values{}, fmt.Errorf("path %q: %s", p, err)
+		}
+	}
+	return root.(map[string]interface{}), nil
+}
+
+// flatSegment is a part of a flattened path: either a table key or a list index.
+type flatSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parseFlatPath splits a path produced by Flatten into its segments. It also
+// returns the leading parts of the path that name the tables and lists the
+// path goes through.
+func parseFlatPath(p string) ([]flatSegment, []string, error) {
+	var segs []flatSegment
+	var prefixes []string
+	var key strings.Builder
+	haveKey := false
+	endKey := func() error {
+		if !haveKey {
+			return fmt.Errorf("path %q has an empty key", p)
+		}
+		segs = append(segs, flatSegment{key: key.String()})
+		key.Reset()
+		haveKey = false
+		return nil
+	}
+
+	rs := []rune(p)
+	for i := 0; i < len(rs); i++ {
+		switch r := rs[i]; r {
+		case '\\':
+			if i++; i == len(rs) {
+				return nil, nil, fmt.Errorf("path %q ends with an escape", p)
+			}
+			key.WriteRune(rs[i])
+			haveKey = true
+		case '.':
+			if err := endKey(); err != nil {
+				return nil, nil, err
+			}
+			prefixes = append(prefixes, string(rs[:i]))
+		case '[':
+			if haveKey || len(segs) == 0 {
+				if err := endKey(); err != nil {
+					return nil, nil, err
+				}
+			}
+			prefixes = append(prefixes, string(rs[:i]))
+			end := i + 1
+			for end < len(rs) && rs[end] != ']' {
+				end++
+			}
+			if end == len(rs) {
+				return nil, nil, fmt.Errorf("path %q has an unterminated list index", p)
+			}
+			idx, err := strconv.Atoi(string(rs[i+1 : end]))
+			if err != nil || idx < 0 {
+				return nil, nil, fmt.Errorf("path %q has an invalid list index %q", p, string(rs[i+1:end]))
+			}
+			segs = append(segs, flatSegment{index: idx, isIndex: true})
+			i = end
+			// An index is followed by another index, a dot or the end of the path.
+			if i+1 < len(rs) && rs[i+1] == '.' {
+				prefixes = append(prefixes, string(rs[:i+1]))
+				i++
+			} else if i+1 < len(rs) && rs[i+1] != '[' {
+				return nil, nil, fmt.Errorf("path %q has unexpected data after a list index", p)
+			}
+		default:
+			key.WriteRune(r)
+			haveKey = true
+		}
+	}
+	if haveKey || len(segs) == 0 || rs[len(rs)-1] == '.' {
+		if err := endKey(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return segs, prefixes, nil
+}
+
+// unflattenSet sets val at segs below cur, creating tables and lists as needed,
+// and returns the updated cur.
+func unflattenSet(cur interface{}, segs []flatSegment, val interface{}) (interface{}, error) {
+	seg := segs[0]
+	if seg.isIndex {
+		if cur == nil {
+			cur = []interface{}{}
+		}
+		l, ok := cur.([]interface{})
+		if !ok {
+			return cur, fmt.Errorf("index [%d] used on a value that is not a list", seg.index)
+		}
+		for len(l) <= seg.index {
+			l = append(l, nil)
+		}
+		if len(segs) == 1 {
+			if l[seg.index] != nil {
+				return l, fmt.Errorf("index [%d] is set more than once", seg.index)
+			}
+			l[seg.index] = val
+			return l, nil
+		}
+		child, err := unflattenSet(l[seg.index], segs[1:], val)
+		l[seg.index] = child
+		return l, err
+	}
+
+	if cur == nil {
+		cur = map[string]interface{}{}
+	}
+	m, ok := cur.(map[string]interface{})
+	if !ok {
+		return cur, fmt.Errorf("key %q used on a value that is not a table", seg.key)
+	}
+	if len(segs) == 1 {
+		if _, ok := m[seg.key]; ok {
+			return m, fmt.Errorf("key %q is set more than once", seg.key)
+		}
+		m[seg.key] = val
+		return m, nil
+	}
+	child, err := unflattenSet(m[seg.key], segs[1:], val)
+	m[seg.key] = child
+	return m, err
+}
+
+// escapeKey escapes the characters in a key that separate the parts of a --set path.
+func escapeKey(k string) string {
+	if !strings.ContainsAny(k, `\.[,=`) {
+		return k
+	}
+	var b strings.Builder
+	for _, r := range k {
+		switch r {
+		case '\\', '.', '[', ',', '=':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
 func tableLookup(v Values, simple string) (Values, error) {
 	v2, ok := v[simple]
 	if !ok {
-		return v, ErrNoTable(fmt.Errorf("no table named %q (%v)", simple, v))
+		return v, missingTable(fmt.Sprintf("no table named %q (%v)", simple, v))
 	}
 	if vv, ok := v2.(map[string]interface{}); ok {
 		return vv, nil
@@ -126,11 +818,19 @@ func tableLookup(v Values, simple string) (Values, error) {
 		return vv, nil
 	}
 
-	var e ErrNoTable = fmt.Errorf("no table named %q", simple)
-	return map[string]interface{}{}, e
+	return map[string]interface{}{}, ErrNotTable{Key: simple}
 }
 
 // ReadValues will parse YAML byte data into a Values.
+//
+// The data is converted to JSON before it is decoded, so every table comes
+// back as a map[string]interface{}, however deeply it is nested. Keys that
+// are numbers or booleans are converted to strings, and keys that cannot be,
+// such as a list used as a key, are an error.
+//
+// The JSON conversion also expands aliases into separate copies of the
+// anchored value, so changing the table at one alias, as coalescing does,
+// never changes the table at another.
 func ReadValues(data []byte) (vals Values, err error) {
 	err = yaml.Unmarshal(data, &vals)
 	if len(vals) == 0 {
@@ -139,13 +839,286 @@ func ReadValues(data []byte) (vals Values, err error) {
 	return
 }
 
+// ReadValuesStrict will parse YAML byte data into a Values, like ReadValues.
+//
+// Where ReadValues silently keeps the last of any duplicated keys,
+// ReadValuesStrict returns an error naming the duplicated key.
+func ReadValuesStrict(data []byte) (Values, error) {
+	var doc interface{}
+	if err := goyaml.UnmarshalStrict(data, &doc); err != nil {
+		return Values{}, err
+	}
+	return ReadValues(data)
+}
+
+// ReadValuesJSON will parse JSON byte data into a Values.
+//
+// Unlike ReadValues, integers are decoded as int64 rather than float64, so
+// large integers such as 64-bit IDs keep their precision. Other numbers, and
+// integers too large for an int64, are decoded as float64. The data must hold
+// a single JSON object; anything after it is an error.
+func ReadValuesJSON(data []byte) (Values, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var vals map[string]interface{}
+	if err := d.Decode(&vals); err != nil {
+		if err == io.EOF {
+			// Empty input is an empty set of values, as it is for ReadValues.
+			return Values{}, nil
+		}
+		return Values{}, err
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return Values{}, errors.New("unexpected data after the JSON object")
+	}
+	if err := convertJSONNumbers(vals); err != nil {
+		return Values{}, err
+	}
+	if vals == nil {
+		return Values{}, nil
+	}
+	return vals, nil
+}
+
+// convertJSONNumbers replaces the json.Number values in vals, and in the
+// tables and lists below it, with an int64 or, failing that, a float64.
+func convertJSONNumbers(vals interface{}) error {
+	switch v := vals.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			n, err := convertJSONNumber(e)
+			if err != nil {
+				return err
+			}
+			v[k] = n
+		}
+	case []interface{}:
+		for i, e := range v {
+			n, err := convertJSONNumber(e)
+			if err != nil {
+				return err
+			}
+			v[i] = n
+		}
+	}
+	return nil
+}
+
+// convertJSONNumber returns v as an int64 or float64 if it is a json.Number,
+// converting the numbers below it otherwise.
+func convertJSONNumber(v interface{}) (interface{}, error) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return v, convertJSONNumbers(v)
+	}
+	if i, err := n.Int64(); err == nil {
+		return i, nil
+	}
+	return n.Float64()
+}
+
+// ReadValuesTOML will parse TOML byte data into a Values.
+//
+// The result has the same shape as ReadValues would give for the equivalent
+// YAML: tables become maps, arrays of tables become lists of maps, numbers are
+// float64, and dates become RFC 3339 strings.
+func ReadValuesTOML(data []byte) (Values, error) {
+	var doc map[string]interface{}
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return Values{}, err
+	}
+	// Round-trip through JSON, as ReadValues does for YAML, so that the
+	// values have the same types as values read from YAML.
+	j, err := json.Marshal(doc)
+	if err != nil {
+		return Values{}, err
+	}
+	return ReadValues(j)
+}
+
+// ReadValuesCoerce will parse YAML byte data into a Values like ReadValues,
+// then convert string leaves that look like booleans or integers.
+//
+// The strings "true" and "false" become bools, and a string that is an
+// integer in canonical form, such as "8080" but not "08080" or "+8080",
+// becomes an int. This helps with values whose types were lost to text
+// substitution, but a value that was meant to be a string, like a version
+// "1", cannot be told apart, so the conversion is opt-in.
+func ReadValuesCoerce(data []byte) (Values, error) {
+	vals, err := ReadValues(data)
+	if err != nil {
+		return vals, err
+	}
+	vals.MapLeaves(func(_ string, val interface{}) interface{} {
+		return coerceString(val)
+	})
+	return vals, nil
+}
+
+func coerceString(val interface{}) interface{} {
+	s, ok := val.(string)
+	if !ok {
+		return val
+	}
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if n, err := strconv.Atoi(s); err == nil && strconv.Itoa(n) == s {
+		return n
+	}
+	return val
+}
+
+// ReadValuesLimited will parse YAML byte data into a Values like ReadValues,
+// but rejects data that is larger than maxBytes before parsing it, and values
+// with more than maxKeys keys in total, counting the keys of every nested
+// table. A limit that is zero or less is not checked.
+//
+// This protects against untrusted values files that are large enough to use
+// up memory.
+func ReadValuesLimited(data []byte, maxBytes, maxKeys int) (Values, error) {
+	if maxBytes > 0 && len(data) > maxBytes {
+		return Values{}, fmt.Errorf("values data is %d bytes, more than the maximum of %d", len(data), maxBytes)
+	}
+	vals, err := ReadValues(data)
+	if err != nil {
+		return vals, err
+	}
+	if maxKeys > 0 {
+		if n := countKeys(vals); n > maxKeys {
+			return Values{}, fmt.Errorf("values have %d keys, more than the maximum of %d", n, maxKeys)
+		}
+	}
+	return vals, nil
+}
+
+// countKeys returns the number of keys in v and in all tables nested in it.
+func countKeys(v interface{}) int {
+	n := 0
+	switch vv := v.(type) {
+	case Values:
+		return countKeys(map[string]interface{}(vv))
+	case map[string]interface{}:
+		for _, val := range vv {
+			n += 1 + countKeys(val)
+		}
+	case []interface{}:
+		for _, val := range vv {
+			n += countKeys(val)
+		}
+	}
+	return n
+}
+
+// ReadValuesDoc will parse the document at the given index of a multi-document
+// YAML stream into a Values. Documents are separated by '---' lines, and the
+// first document has index 0.
+//
+// An error is returned if there is no document at index.
+func ReadValuesDoc(data []byte, index int) (Values, error) {
+	docs, err := splitValuesDocuments(data)
+	if err != nil {
+		return Values{}, err
+	}
+	if index < 0 || index >= len(docs) {
+		return Values{}, fmt.Errorf("document index %d is out of range, found %d documents", index, len(docs))
+	}
+	return ReadValues(docs[index])
+}
+
+// ReadValuesMerged will parse every document of a multi-document YAML stream
+// and merge them into a single Values, in order. Later documents override
+// earlier ones the way CoalesceTables does: tables are merged, while other
+// values, including lists, are replaced.
+func ReadValuesMerged(data []byte) (Values, error) {
+	docs, err := splitValuesDocuments(data)
+	if err != nil {
+		return Values{}, err
+	}
+	merged := map[string]interface{}{}
+	for i, doc := range docs {
+		v, err := ReadValues(doc)
+		if err != nil {
+			return Values{}, fmt.Errorf("document %d: %s", i, err)
+		}
+		merged = CoalesceTables(v, merged)
+	}
+	return merged, nil
+}
+
+// splitValuesDocuments splits a YAML stream into one YAML encoded byte slice
+// per document.
+func splitValuesDocuments(data []byte) ([][]byte, error) {
+	var docs [][]byte
+	d := goyaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc interface{}
+		if err := d.Decode(&doc); err == io.EOF {
+			return docs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		out, err := goyaml.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, out)
+	}
+}
+
+// ReadValuesReader will parse YAML data from a reader into a Values, like
+// ReadValues.
+//
+// Only the first document in the stream is parsed. This is not a streaming
+// parse: the document is decoded, marshalled back to YAML and parsed again by
+// ReadValues, so that keys and numbers are converted the same way, and each
+// step holds the whole document in memory.
+func ReadValuesReader(r io.Reader) (Values, error) {
+	var doc interface{}
+	if err := goyaml.NewDecoder(r).Decode(&doc); err != nil {
+		if err == io.EOF {
+			// Empty input is an empty set of values, as it is for ReadValues.
+			return Values{}, nil
+		}
+		return Values{}, err
+	}
+	out, err := goyaml.Marshal(doc)
+	if err != nil {
+		return Values{}, err
+	}
+	return ReadValues(out)
+}
+
+// MergeValueFiles will parse each of the given YAML documents and merge them
+// in order, with the values in later documents overriding those in earlier
+// ones, as with repeated --values flags.
+//
+// Tables are merged key by key, and any other value is replaced. A key set to
+// null is kept, so that when the result is coalesced with a chart, the null
+// still removes the chart's default as it would have in a single file.
+func MergeValueFiles(files [][]byte) (Values, error) {
+	merged := Values{}
+	for i, data := range files {
+		vals, err := ReadValues(data)
+		if err != nil {
+			return merged, fmt.Errorf("parsing values file %d: %s", i, err)
+		}
+		merged.MergeInto(vals)
+	}
+	return merged, nil
+}
+
 // ReadValuesFile will parse a YAML file into a map of values.
 func ReadValuesFile(filename string) (Values, error) {
-	data, err := ioutil.ReadFile(filename)
+	f, err := os.Open(filename)
 	if err != nil {
 		return map[string]interface{}{}, err
 	}
-	return ReadValues(data)
+	defer f.Close()
+	return ReadValuesReader(f)
 }
 
 // CoalesceValues coalesces all of the values in a chart (and its subcharts).
@@ -158,56 +1131,342 @@ func ReadValuesFile(filename string) (Values, error) {
 //	- A chart has access to all of the variables for it, as well as all of
 //		the values destined for its dependencies.
 func CoalesceValues(chrt *chart.Chart, vals *chart.Config) (Values, error) {
-	cvals := Values{}
-	// Parse values if not nil. We merge these at the top level because
-	// the passed-in values are in the same namespace as the parent chart.
+	var evals Values
 	if vals != nil {
-		evals, err := ReadValues([]byte(vals.Raw))
+		var err error
+		evals, err = ReadValues([]byte(vals.Raw))
 		if err != nil {
-			return cvals, err
+			return Values{}, err
 		}
-		cvals, err = coalesce(chrt, evals)
+	}
+	c := coalescer{ctx: context.Background()}
+	return c.coalesceTop(chrt, evals)
+}
+
+// CoalesceValuesContext coalesces the given values with the values in a chart
+// (and its subcharts) like CoalesceValues, but stops with the context's error
+// as soon as it sees that ctx is done. The context is checked before each
+// chart in the tree is coalesced.
+//
+// The vals map is not modified.
+func CoalesceValuesContext(ctx context.Context, chrt *chart.Chart, vals map[string]interface{}) (Values, error) {
+	c := coalescer{ctx: ctx}
+	return c.coalesceCopy(chrt, vals)
+}
+
+// MergeValues coalesces the given values with the values in a chart (and its
+// subcharts).
+//
+// MergeValues follows the same rules as CoalesceValues, with one exception:
+// CoalesceValues treats a key set to null as a request to delete that key,
+// while MergeValues keeps the key in the result with a nil value. Use
+// MergeValues when an explicit null is meant to be passed through to the
+// chart rather than to remove the chart's default.
+//
+// The vals map is not modified.
+func MergeValues(chrt *chart.Chart, vals map[string]interface{}) (Values, error) {
+	c := coalescer{keepNulls: true}
+	return c.coalesceCopy(chrt, vals)
+}
+
+const (
+	// ReplaceSuffix, added to a key in the given values, makes a table under
+	// that key replace the chart's default table instead of being merged
+	// with it, as if its path were listed in CoalesceValuesOptions.ReplacePaths.
+	// It is only applied with CoalesceValuesOptions.KeyDirectives set.
+	ReplaceSuffix = "+replace"
+	// MergeSuffix, added to a key in the given values, makes a table under that
+	// key be merged with the chart's default table. This is the default, but
+	// it overrides a path listed in CoalesceValuesOptions.ReplacePaths. It is
+	// only applied with CoalesceValuesOptions.KeyDirectives set.
+	MergeSuffix = "+merge"
+)
+
+// CoalesceValuesOptions controls the behavior of CoalesceValuesWithOptions.
+type CoalesceValuesOptions struct {
+	// PreserveNullPaths is a list of dotted paths, starting at the top-level
+	// chart, under which a null value is kept rather than removing the key.
+	//
+	// A path also covers everything below it, so "secrets" covers
+	// "secrets.token", and "pequod.secrets" covers the secrets of the pequod
+	// subchart. A "*" matches any single key.
+	PreserveNullPaths []string
+	// ReplacePaths is a list of dotted paths, starting at the top-level
+	// chart, where a table in the given values replaces the chart's default
+	// table instead of being merged with it.
+	//
+	// For example, with "resources" listed, setting resources.limits drops
+	// the chart's default resources.requests. A "*" matches any single key.
+	ReplacePaths []string
+	// KeyDirectives strips a ReplaceSuffix or MergeSuffix from the keys in
+	// the given values and applies it. Without it, such keys are kept as
+	// they are, like any other key.
+	KeyDirectives bool
+	// OnSubchart, if set, is called once for each subchart before the
+	// subchart's default values are merged in. It is given the dotted path of
+	// the subchart, such as "pequod.ahab", and the number of keys, counting
+	// nested keys, in the values passed down to it, including globals.
+	OnSubchart func(name string, keys int)
+	// MaxDepth is how deeply subcharts, and tables and lists within the
+	// values, may be nested before an ErrMaxDepth is returned. Zero means
+	// DefaultMaxDepth. Globals are merged as CoalesceTables merges them, so
+	// globals nested more deeply than DefaultMaxDepth are left out with a
+	// warning instead.
+	MaxDepth int
+}
+
+// CoalesceValuesWithOptions coalesces the given values with the values in a
+// chart (and its subcharts) like CoalesceValues, with the changes given in
+// opts.
+//
+// The vals map is not modified.
+func CoalesceValuesWithOptions(chrt *chart.Chart, vals map[string]interface{}, opts CoalesceValuesOptions) (Values, error) {
+	c := coalescer{
+		preserveNulls: opts.PreserveNullPaths,
+		replace:       append([]string(nil), opts.ReplacePaths...),
+		directives:    opts.KeyDirectives,
+		onSubchart:    opts.OnSubchart,
+		reported:      map[string]bool{},
+		maxDepth:      opts.MaxDepth,
+	}
+	return c.coalesceCopy(chrt, vals)
+}
+
+// Override records a chart default that was overridden while coalescing.
+type Override struct {
+	// Path is the dotted path of the overridden value, starting at the top-level chart.
+	Path string
+	// Default is the value from the chart's values.yaml.
+	Default interface{}
+	// Value is the value that replaced the default. It is nil if the default
+	// was removed by setting it to null.
+	Value interface{}
+}
+
+// CoalesceValuesExplain coalesces the given values with the values in a chart
+// (and its subcharts) like CoalesceValues, and also reports every chart default
+// that was overridden.
+//
+// Tables are not reported themselves; only the values inside them that differ
+// from the defaults are. A value that is set to the same value as the default
+// is not reported either. The vals map is not modified.
+func CoalesceValuesExplain(chrt *chart.Chart, vals map[string]interface{}) (Values, []Override, error) {
+	c := coalescer{explain: true, seen: map[string]bool{}}
+	cvals, err := c.coalesceCopy(chrt, vals)
+	return cvals, c.overrides, err
+}
+
+// CoalesceGlobalsWithDepth coalesces the given values with the values in a
+// chart (and its subcharts) like CoalesceValues, but only copies the globals
+// down to subcharts that are at most maxDepth levels below the chart.
+//
+// A maxDepth of 1 gives globals to the chart's direct dependencies but not to
+// their dependencies, and a maxDepth of 0 keeps the globals in the top-level
+// chart. A negative maxDepth does not limit the depth. A subchart beyond the
+// limit only sees the globals set directly on it. The vals map is not
+// modified.
+func CoalesceGlobalsWithDepth(chrt *chart.Chart, vals map[string]interface{}, maxDepth int) (Values, error) {
+	c := coalescer{limitGlobals: maxDepth >= 0, globalsDepth: maxDepth}
+	return c.coalesceCopy(chrt, vals)
+}
+
+// SubchartValues coalesces the given values with the values in a chart (and
+// its subcharts) like CoalesceValues, and returns only the values that the
+// subchart at subchartPath sees, including the globals copied down to it.
+//
+// The subchartPath is a dotted list of chart names below chrt, such as
+// "pequod" or "pequod.ahab". As with Table, a *PathError is returned if no
+// table exists at that path. The vals map is not modified.
+func SubchartValues(chrt *chart.Chart, vals map[string]interface{}, subchartPath string) (Values, error) {
+	c := coalescer{ctx: context.Background()}
+	cvals, err := c.coalesceCopy(chrt, vals)
+	if err != nil {
+		return cvals, err
+	}
+	return cvals.Table(subchartPath)
+}
+
+// coalescer holds the settings for a single pass of coalescing values over a
+// chart and its dependencies.
+type coalescer struct {
+	// keepNulls keeps keys whose value is null instead of removing them, and
+	// preserveNulls does so only under the given paths.
+	keepNulls     bool
+	preserveNulls []string
+	// replace holds the paths where given tables replace the chart's tables,
+	// and merge the paths where they are merged even if listed in replace.
+	replace []string
+	merge   []string
+	// directives applies the ReplaceSuffix and MergeSuffix in the given keys.
+	directives bool
+
+	// explain records the chart defaults that are overridden in overrides.
+	explain   bool
+	overrides []Override
+	// seen holds the paths already in overrides, as dependencies may be
+	// coalesced more than once.
+	seen map[string]bool
+
+	// limitGlobals stops globals from being copied to subcharts more than
+	// globalsDepth levels below the top-level chart.
+	limitGlobals bool
+	globalsDepth int
+
+	// path is the dotted path of the chart currently being coalesced, and
+	// depth is its number of levels below the top-level chart.
+	path  string
+	depth int
+	// maxDepth limits the nesting of charts and values. Zero means
+	// DefaultMaxDepth.
+	maxDepth int
+
+	// onSubchart, if set, is called for each subchart, and reported holds the
+	// subcharts it has been called for, as dependencies may be coalesced more
+	// than once.
+	onSubchart func(name string, keys int)
+	reported   map[string]bool
+
+	// ctx, if set, cancels coalescing.
+	ctx context.Context
+}
+
+// coalesceTop coalesces the values given for a top-level chart with the chart.
+//
+// If vals is nil, only the chart's dependencies are coalesced.
+func (c *coalescer) coalesceTop(chrt *chart.Chart, vals map[string]interface{}) (Values, error) {
+	cvals := Values{}
+	// We merge the given values at the top level because they are in the
+	// same namespace as the parent chart.
+	if vals != nil {
+		var err error
+		cvals, err = c.coalesce(chrt, vals)
 		if err != nil {
 			return cvals, err
 		}
 	}
 
-	var err error
-	cvals, err = coalesceDeps(chrt, cvals)
-	return cvals, err
+	var err error
+	cvals, err = c.coalesceDeps(chrt, cvals)
+	return cvals, err
+}
+
+// coalesceCopy coalesces a deep copy of the values given for a top-level
+// chart with the chart, so that vals is not modified.
+func (c *coalescer) coalesceCopy(chrt *chart.Chart, vals map[string]interface{}) (Values, error) {
+	cvals := Values(vals).DeepCopy()
+	if cvals == nil {
+		cvals = Values{}
+	}
+	return c.coalesceTop(chrt, cvals)
+}
+
+// coalesce coalesces the dest values and the chart values, giving priority to the dest values.
+//
+// This is a helper function for CoalesceValues.
+func (c *coalescer) coalesce(ch *chart.Chart, dest map[string]interface{}) (map[string]interface{}, error) {
+	if c.ctx != nil {
+		if err := c.ctx.Err(); err != nil {
+			return dest, err
+		}
+	}
+	if c.directives && c.depth == 0 {
+		if err := c.applyDirectives("", dest); err != nil {
+			return dest, err
+		}
+	}
+	var err error
+	dest, err = c.coalesceValues(ch, dest)
+	if err != nil {
+		return dest, err
+	}
+	// Errors in the dependencies are not returned here, as the dependencies
+	// of the top-level chart are coalesced again by the caller, except for
+	// the errors that stop coalescing altogether.
+	if _, err := c.coalesceDeps(ch, dest); c.stops(err) {
+		return dest, err
+	}
+	return dest, nil
+}
+
+// stops reports whether err stops coalescing, which is the case for an
+// ErrMaxDepth or for the error of a context that is done.
+func (c *coalescer) stops(err error) bool {
+	if _, ok := err.(ErrMaxDepth); ok {
+		return true
+	}
+	return err != nil && c.ctx != nil && err == c.ctx.Err()
 }
 
-// coalesce coalesces the dest values and the chart values, giving priority to the dest values.
+// tableOptions returns the options for coalescing the tables in values.
+func (c *coalescer) tableOptions() CoalesceOptions {
+	return CoalesceOptions{MaxDepth: c.maxDepth}
+}
+
+// applyDirectives strips the ReplaceSuffix and MergeSuffix from the keys in
+// vals and below, recording their paths in replace and merge.
 //
-// This is a helper function for CoalesceValues.
-func coalesce(ch *chart.Chart, dest map[string]interface{}) (map[string]interface{}, error) {
-	var err error
-	dest, err = coalesceValues(ch, dest)
-	if err != nil {
-		return dest, err
+// Lists are never merged by coalescing, so a directive on a list key has no
+// effect beyond the key being renamed.
+func (c *coalescer) applyDirectives(prefix string, vals map[string]interface{}) error {
+	for _, key := range sortedKeys(vals) {
+		val := vals[key]
+		name := key
+		switch {
+		case strings.HasSuffix(key, ReplaceSuffix):
+			name = strings.TrimSuffix(key, ReplaceSuffix)
+			c.replace = append(c.replace, joinPath(prefix, name))
+		case strings.HasSuffix(key, MergeSuffix):
+			name = strings.TrimSuffix(key, MergeSuffix)
+			c.merge = append(c.merge, joinPath(prefix, name))
+		}
+		if name != key {
+			if _, ok := vals[name]; ok {
+				return fmt.Errorf("values key %q conflicts with %q", joinPath(prefix, key), joinPath(prefix, name))
+			}
+			delete(vals, key)
+			vals[name] = val
+		}
+		if t, ok := val.(map[string]interface{}); ok {
+			if err := c.applyDirectives(joinPath(prefix, name), t); err != nil {
+				return err
+			}
+		}
 	}
-	coalesceDeps(ch, dest)
-	return dest, nil
+	return nil
 }
 
 // coalesceDeps coalesces the dependencies of the given chart.
-func coalesceDeps(chrt *chart.Chart, dest map[string]interface{}) (map[string]interface{}, error) {
+func (c *coalescer) coalesceDeps(chrt *chart.Chart, dest map[string]interface{}) (map[string]interface{}, error) {
+	if limit := c.tableOptions().maxDepth(); c.depth >= limit {
+		return dest, ErrMaxDepth{Depth: limit}
+	}
 	for _, subchart := range chrt.Dependencies {
-		if c, ok := dest[subchart.Metadata.Name]; !ok {
+		if v, ok := dest[subchart.Metadata.Name]; !ok {
 			// If dest doesn't already have the key, create it.
 			dest[subchart.Metadata.Name] = map[string]interface{}{}
-		} else if !istable(c) {
-			return dest, fmt.Errorf("type mismatch on %s: %t", subchart.Metadata.Name, c)
+		} else if !istable(v) {
+			return dest, fmt.Errorf("type mismatch on %s: %t", subchart.Metadata.Name, v)
 		}
 		if dv, ok := dest[subchart.Metadata.Name]; ok {
 			dvmap := dv.(map[string]interface{})
 
 			// Get globals out of dest and merge them into dvmap.
-			coalesceGlobals(dvmap, dest, chrt.Metadata.Name)
+			if !c.limitGlobals || c.depth < c.globalsDepth {
+				coalesceGlobals(dvmap, dest, chrt.Metadata.Name)
+			}
 
 			var err error
 			// Now coalesce the rest of the values.
-			dest[subchart.Metadata.Name], err = coalesce(subchart, dvmap)
+			path := c.path
+			c.path = joinPath(path, subchart.Metadata.Name)
+			if c.onSubchart != nil && !c.reported[c.path] {
+				c.reported[c.path] = true
+				c.onSubchart(c.path, countKeys(dvmap))
+			}
+			c.depth++
+			dest[subchart.Metadata.Name], err = c.coalesce(subchart, dvmap)
+			c.path = path
+			c.depth--
 			if err != nil {
 				return dest, err
 			}
@@ -269,6 +1528,154 @@ func coalesceGlobals(dest, src map[string]interface{}, chartName string) map[str
 	return dest
 }
 
+// ExtractGlobals returns the table of globals in vals, which is the table
+// under GlobalKey. It returns an empty table if vals has no globals, or if
+// they are not a table.
+//
+// The returned table is the one in vals, not a copy.
+func ExtractGlobals(vals map[string]interface{}) map[string]interface{} {
+	switch g := vals[GlobalKey].(type) {
+	case map[string]interface{}:
+		return g
+	case Values:
+		return g
+	}
+	return map[string]interface{}{}
+}
+
+// GlobalConflict is a global key that charts in the same tree declare with
+// different values.
+type GlobalConflict struct {
+	// Key is the dotted path of the key inside the global table.
+	Key string
+	// Values maps the path of each chart that declares the key, such as
+	// "moby/pequod", to the value it declares.
+	Values map[string]interface{}
+}
+
+// ValidateGlobals reports the global keys that the charts in a tree declare
+// with different values in their values.yaml files.
+//
+// Globals are copied down from a chart to its dependencies, so a chart always
+// overrides the globals of the charts below it. Only charts where neither is
+// an ancestor of the other are compared, such as two sibling subcharts, as
+// these disagree without one overriding the other. Nested tables are compared
+// key by key, and any other value is compared as a whole.
+//
+// The conflicts are sorted by key.
+func ValidateGlobals(chrt *chart.Chart) ([]GlobalConflict, error) {
+	decls := map[string][]globalDecl{}
+	if err := collectGlobals(chrt, chrt.Metadata.Name, decls); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(decls))
+	for k := range decls {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var conflicts []GlobalConflict
+	for _, k := range keys {
+		if !globalsConflict(decls[k]) {
+			continue
+		}
+		vals := make(map[string]interface{}, len(decls[k]))
+		for _, d := range decls[k] {
+			vals[d.chart] = d.val
+		}
+		conflicts = append(conflicts, GlobalConflict{Key: k, Values: vals})
+	}
+	return conflicts, nil
+}
+
+// ValidateSubchartOverrides returns the sorted top-level keys in vals that
+// are neither keys in the chart's values.yaml nor the names of its
+// dependencies, other than the globals.
+//
+// Such keys are kept by coalescing but used by no chart, so they usually point
+// to a misspelled subchart name. A ReplaceSuffix or MergeSuffix is ignored, as
+// CoalesceValuesOptions.KeyDirectives strips it. If
+// the chart's values.yaml does not parse, it is treated as empty.
+func ValidateSubchartOverrides(chrt *chart.Chart, vals map[string]interface{}) []string {
+	known := map[string]bool{GlobalKey: true}
+	if chrt.Values != nil && chrt.Values.Raw != "" {
+		if defaults, err := ReadValues([]byte(chrt.Values.Raw)); err == nil {
+			for k := range defaults {
+				known[k] = true
+			}
+		}
+	}
+	for _, dep := range chrt.Dependencies {
+		known[dep.Metadata.Name] = true
+	}
+
+	var unknown []string
+	for _, k := range sortedKeys(vals) {
+		name := strings.TrimSuffix(strings.TrimSuffix(k, ReplaceSuffix), MergeSuffix)
+		if !known[name] {
+			unknown = append(unknown, k)
+		}
+	}
+	return unknown
+}
+
+// globalDecl is a global value declared by a chart.
+type globalDecl struct {
+	chart string
+	val   interface{}
+}
+
+// collectGlobals adds the globals declared by ch and its dependencies to decls, keyed by path.
+func collectGlobals(ch *chart.Chart, chartPath string, decls map[string][]globalDecl) error {
+	if ch.Values != nil && ch.Values.Raw != "" {
+		vals, err := ReadValues([]byte(ch.Values.Raw))
+		if err != nil {
+			return fmt.Errorf("Error: Reading chart '%s' default values (%s): %s", ch.Metadata.Name, ch.Values.Raw, err)
+		}
+		if g, ok := vals[GlobalKey].(map[string]interface{}); ok {
+			collectGlobalValues(chartPath, "", g, decls)
+		}
+	}
+	for _, dep := range ch.Dependencies {
+		if err := collectGlobals(dep, chartPath+"/"+dep.Metadata.Name, decls); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func collectGlobalValues(chartPath, prefix string, g map[string]interface{}, decls map[string][]globalDecl) {
+	for key, val := range g {
+		p := joinPath(prefix, key)
+		if t, ok := val.(map[string]interface{}); ok {
+			collectGlobalValues(chartPath, p, t, decls)
+			continue
+		}
+		decls[p] = append(decls[p], globalDecl{chart: chartPath, val: val})
+	}
+}
+
+// globalsConflict reports whether two unrelated charts declare different values.
+func globalsConflict(decls []globalDecl) bool {
+	for i, a := range decls {
+		for _, b := range decls[i+1:] {
+			if isChartAncestor(a.chart, b.chart) || isChartAncestor(b.chart, a.chart) {
+				continue
+			}
+			if !reflect.DeepEqual(a.val, b.val) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isChartAncestor reports whether the chart at path a is an ancestor of the chart at path b.
+func isChartAncestor(a, b string) bool {
+	return strings.HasPrefix(b, a+"/")
+}
+
 func copyMap(src map[string]interface{}) map[string]interface{} {
 	dest := make(map[string]interface{}, len(src))
 	for k, v := range src {
@@ -280,18 +1687,22 @@ func copyMap(src map[string]interface{}) map[string]interface{} {
 // coalesceValues builds up a values map for a particular chart.
 //
 // Values in v will override the values in the chart.
-func coalesceValues(c *chart.Chart, v map[string]interface{}) (map[string]interface{}, error) {
+func (c *coalescer) coalesceValues(ch *chart.Chart, v map[string]interface{}) (map[string]interface{}, error) {
 	// If there are no values in the chart, we just return the given values
-	if c.Values == nil || c.Values.Raw == "" {
+	if ch.Values == nil || ch.Values.Raw == "" {
 		return v, nil
 	}
 
-	nv, err := ReadValues([]byte(c.Values.Raw))
+	nv, err := ReadValues([]byte(ch.Values.Raw))
 	if err != nil {
 		// On error, we return just the overridden values.
 		// FIXME: We should log this error. It indicates that the YAML data
 		// did not parse.
-		return v, fmt.Errorf("Error: Reading chart '%s' default values (%s): %s", c.Metadata.Name, c.Values.Raw, err)
+		return v, fmt.Errorf("Error: Reading chart '%s' default values (%s): %s", ch.Metadata.Name, ch.Values.Raw, err)
+	}
+
+	if c.explain {
+		c.recordOverrides(c.path, v, nv)
 	}
 
 	for key, val := range nv {
@@ -300,17 +1711,21 @@ func coalesceValues(c *chart.Chart, v map[string]interface{}) (map[string]interf
 				// When the YAML value is null, we remove the value's key.
 				// This allows Helm's various sources of values (value files or --set) to
 				// remove incompatible keys from any previous chart, file, or set values.
-				delete(v, key)
+				if !c.keepNull(joinPath(c.path, key)) {
+					delete(v, key)
+				}
 			} else if dest, ok := value.(map[string]interface{}); ok {
 				// if v[key] is a table, merge nv's val table into v[key].
 				src, ok := val.(map[string]interface{})
 				if !ok {
-					log.Printf("Warning: Building values map for chart '%s'. Skipped value (%+v) for '%s', as it is not a table.", c.Metadata.Name, src, key)
+					log.Printf("Warning: Building values map for chart '%s'. Skipped value (%+v) for '%s', as it is not a table.", ch.Metadata.Name, src, key)
 					continue
 				}
 				// Because v has higher precedence than nv, dest values override src
 				// values.
-				coalesceTables(dest, src, c.Metadata.Name)
+				if err := c.coalesceTable(joinPath(c.path, key), dest, src, ch.Metadata.Name); err != nil {
+					return v, err
+				}
 			}
 		} else {
 			// If the key is not in v, copy it from nv.
@@ -320,10 +1735,261 @@ func coalesceValues(c *chart.Chart, v map[string]interface{}) (map[string]interf
 	return v, nil
 }
 
+// coalesceTable merges the chart's src table at the given path into the dest
+// table, leaving alone the tables in dest that replace the chart's.
+func (c *coalescer) coalesceTable(p string, dest, src map[string]interface{}, chartName string) error {
+	if c.replaced(p) {
+		return nil
+	}
+	if !c.replacedBelow(p) {
+		_, err := coalesceTablesDepth(dest, src, chartName, c.tableOptions(), 0)
+		return err
+	}
+	for key, val := range src {
+		dv, ok := dest[key]
+		if !ok {
+			dest[key] = val
+			continue
+		}
+		dt, dok := dv.(map[string]interface{})
+		st, sok := val.(map[string]interface{})
+		if dok && sok {
+			if err := c.coalesceTable(joinPath(p, key), dt, st, chartName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// replaced reports whether a table at the given path replaces the chart's table.
+func (c *coalescer) replaced(p string) bool {
+	for _, m := range c.merge {
+		if m == p {
+			return false
+		}
+	}
+	segments := strings.Split(p, ".")
+	for _, r := range c.replace {
+		pattern := strings.Split(r, ".")
+		if len(pattern) == len(segments) && matchPathPrefix(pattern, segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// replacedBelow reports whether any table below the given path replaces the
+// chart's table.
+func (c *coalescer) replacedBelow(p string) bool {
+	segments := strings.Split(p, ".")
+	for _, r := range c.replace {
+		pattern := strings.Split(r, ".")
+		if len(pattern) > len(segments) && matchPathPrefix(pattern[:len(segments)], segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// keepNull reports whether a null value at the given path is kept.
+func (c *coalescer) keepNull(p string) bool {
+	if c.keepNulls {
+		return true
+	}
+	segments := strings.Split(p, ".")
+	for _, preserve := range c.preserveNulls {
+		if matchPathPrefix(strings.Split(preserve, "."), segments) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordOverrides records every value in vals that overrides a different value in defaults.
+func (c *coalescer) recordOverrides(prefix string, vals, defaults map[string]interface{}) {
+	for _, key := range sortedKeys(defaults) {
+		val, ok := vals[key]
+		if !ok {
+			continue
+		}
+		def := defaults[key]
+		p := joinPath(prefix, key)
+		if istable(val) && istable(def) {
+			c.recordOverrides(p, val.(map[string]interface{}), def.(map[string]interface{}))
+			continue
+		}
+		if c.seen[p] || reflect.DeepEqual(val, def) {
+			continue
+		}
+		c.seen[p] = true
+		c.overrides = append(c.overrides, Override{Path: p, Default: def, Value: val})
+	}
+}
+
+// ListStrategy controls how a list in the source map is combined with a list
+// in the destination map when coalescing tables.
+type ListStrategy int
+
+const (
+	// ListReplace is the default strategy: the destination list replaces the
+	// source list, which is ignored, as the destination wins for any other
+	// value. It is the zero value, so it is also used when no strategy is
+	// given.
+	ListReplace ListStrategy = iota
+	// ListMergeByIndex coalesces element i of the source list into element i
+	// of the destination list. It is meant for ordered lists whose positions
+	// are stable, such as a fixed set of stages. If the lists differ in
+	// length, the source elements are appended to the destination list.
+	ListMergeByIndex
+	// ListAppend appends the elements of the source list to the destination list.
+	ListAppend
+	// ListPrepend inserts the elements of the source list ahead of the
+	// elements of the destination list.
+	ListPrepend
+)
+
+// CoalesceOptions controls the behavior of CoalesceTablesWithOptions.
+type CoalesceOptions struct {
+	// ListStrategy is the strategy used when both maps have a list for the same key.
+	ListStrategy ListStrategy
+	// MaxDepth is how deeply nested tables and lists are merged before an
+	// ErrMaxDepth is returned. Zero means DefaultMaxDepth.
+	MaxDepth int
+}
+
+func (o CoalesceOptions) maxDepth() int {
+	if o.MaxDepth > 0 {
+		return o.MaxDepth
+	}
+	return DefaultMaxDepth
+}
+
+// CoalesceTables merges a source map into a destination map.
+//
+// dst is considered authoritative: its values override the values in src, and
+// nested tables are merged. Lists are not merged; a list in dst is kept as is.
+//
+// dst is modified in place and returned. If dst is nil, a new map is created,
+// so the returned map must be used.
+//
+// Values nested more deeply than DefaultMaxDepth are left out, and a warning
+// is logged. Use CoalesceTablesWithOptions to get an ErrMaxDepth instead.
+func CoalesceTables(dst, src map[string]interface{}) map[string]interface{} {
+	return coalesceTables(dst, src, "")
+}
+
+// CoalesceTablesStrict merges a source map into a destination map like
+// CoalesceTables, but first checks that the two have the same shape.
+//
+// If a key holds a table in one map and a non-table value in the other,
+// CoalesceTables keeps the dst value and logs a warning. CoalesceTablesStrict
+// instead returns an ErrTableMismatch listing every such key, and leaves dst
+// unchanged. A null value matches anything, as it is used to remove a key.
+//
+// dst is modified in place, so it must not be nil unless src is empty.
+func CoalesceTablesStrict(dst, src map[string]interface{}) error {
+	var mismatches []string
+	findTableMismatches("", dst, src, &mismatches)
+	if len(mismatches) > 0 {
+		sort.Strings(mismatches)
+		return ErrTableMismatch{Paths: mismatches}
+	}
+	if dst == nil && len(src) > 0 {
+		return errors.New("cannot coalesce values into a nil map")
+	}
+	coalesceTables(dst, src, "")
+	return nil
+}
+
+// findTableMismatches adds the paths of the keys that hold a table in one map
+// and a non-table value in the other to mismatches.
+func findTableMismatches(prefix string, dst, src map[string]interface{}, mismatches *[]string) {
+	for key, sv := range src {
+		dv, ok := dst[key]
+		if !ok || dv == nil || sv == nil {
+			continue
+		}
+		p := joinPath(prefix, key)
+		switch dt, st := istable(dv), istable(sv); {
+		case dt && st:
+			findTableMismatches(p, dv.(map[string]interface{}), sv.(map[string]interface{}), mismatches)
+		case dt != st:
+			*mismatches = append(*mismatches, p)
+		}
+	}
+}
+
+// CoalesceAtPath merges a source map into the table at the given dotted path
+// in a destination map, leaving the rest of dst alone.
+//
+// As with CoalesceTables, the values already in the table at path override
+// the values in src. Missing tables along the path are created, so dst must
+// not be nil. A *PathError is returned if the path is empty, or if part of it
+// is not a table. As with CoalesceTables, values nested too deeply are left
+// out with a warning.
+func CoalesceAtPath(dst, src map[string]interface{}, path string) error {
+	if len(path) == 0 {
+		return &PathError{Path: path, Err: ErrEmptyPath}
+	}
+	table := dst
+	for _, n := range strings.Split(path, ".") {
+		val, ok := table[n]
+		if !ok {
+			next := map[string]interface{}{}
+			table[n] = next
+			table = next
+			continue
+		}
+		switch t := val.(type) {
+		case map[string]interface{}:
+			table = t
+		case Values:
+			table = t
+		default:
+			return &PathError{Path: path, Segment: n, Err: ErrNotTable{Key: n}}
+		}
+	}
+	CoalesceTables(table, src)
+	return nil
+}
+
+// CoalesceTablesWithOptions merges a source map into a destination map.
+//
+// As with the rest of the coalescing functions, dst is considered
+// authoritative: its values override the values in src, and nested tables are
+// merged. opts controls how lists found under the same key are combined.
+//
+// As with CoalesceTables, a nil dst is replaced by a new map, so the returned
+// map must be used.
+//
+// If the maps are nested more deeply than opts allows, an ErrMaxDepth is
+// returned, and the values nested too deeply are not merged.
+func CoalesceTablesWithOptions(dst, src map[string]interface{}, opts CoalesceOptions) (map[string]interface{}, error) {
+	return coalesceTablesDepth(dst, src, "", opts, 0)
+}
+
 // coalesceTables merges a source map into a destination map.
 //
-// dest is considered authoritative.
+// dest is considered authoritative. Values nested more deeply than
+// DefaultMaxDepth are not merged, and a warning is logged.
 func coalesceTables(dst, src map[string]interface{}, chartName string) map[string]interface{} {
+	dst, err := coalesceTablesDepth(dst, src, chartName, CoalesceOptions{}, 0)
+	if err != nil {
+		log.Printf("Warning: Merging destination map for chart '%s'. Skipped nested values: %s", chartName, err)
+	}
+	return dst
+}
+
+// coalesceTablesDepth merges a source map at the given depth into a
+// destination map, returning an ErrMaxDepth once it is nested too deeply.
+func coalesceTablesDepth(dst, src map[string]interface{}, chartName string, opts CoalesceOptions, depth int) (map[string]interface{}, error) {
+	if dst == nil {
+		dst = make(map[string]interface{}, len(src))
+	}
+	if depth >= opts.maxDepth() {
+		return dst, ErrMaxDepth{Depth: opts.maxDepth()}
+	}
 	// Because dest has higher precedence than src, dest values override src
 	// values.
 	for key, val := range src {
@@ -331,7 +1997,9 @@ func coalesceTables(dst, src map[string]interface{}, chartName string) map[strin
 			if innerdst, ok := dst[key]; !ok {
 				dst[key] = val
 			} else if istable(innerdst) {
-				coalesceTables(innerdst.(map[string]interface{}), val.(map[string]interface{}), chartName)
+				if _, err := coalesceTablesDepth(innerdst.(map[string]interface{}), val.(map[string]interface{}), chartName, opts, depth+1); err != nil {
+					return dst, err
+				}
 			} else {
 				log.Printf("Warning: Merging destination map for chart '%s'. Cannot overwrite table item '%s', with non table value: %v", chartName, key, val)
 			}
@@ -342,9 +2010,61 @@ func coalesceTables(dst, src map[string]interface{}, chartName string) map[strin
 		} else if !ok { // <- ok is still in scope from preceding conditional.
 			dst[key] = val
 			continue
+		} else if dl, ok := dv.([]interface{}); ok {
+			if sl, ok := val.([]interface{}); ok {
+				l, err := coalesceLists(dl, sl, chartName, opts, depth+1)
+				dst[key] = l
+				if err != nil {
+					return dst, err
+				}
+			}
 		}
 	}
-	return dst
+	return dst, nil
+}
+
+// coalesceLists combines a source list with a destination list according to
+// the list strategy in opts.
+func coalesceLists(dst, src []interface{}, chartName string, opts CoalesceOptions, depth int) ([]interface{}, error) {
+	switch opts.ListStrategy {
+	case ListMergeByIndex:
+		if len(dst) != len(src) {
+			return appendLists(dst, src), nil
+		}
+		if depth >= opts.maxDepth() {
+			return dst, ErrMaxDepth{Depth: opts.maxDepth()}
+		}
+		for i, val := range src {
+			switch dv := dst[i].(type) {
+			case map[string]interface{}:
+				if sv, ok := val.(map[string]interface{}); ok {
+					if _, err := coalesceTablesDepth(dv, sv, chartName, opts, depth+1); err != nil {
+						return dst, err
+					}
+				}
+			case []interface{}:
+				if sv, ok := val.([]interface{}); ok {
+					l, err := coalesceLists(dv, sv, chartName, opts, depth+1)
+					dst[i] = l
+					if err != nil {
+						return dst, err
+					}
+				}
+			}
+		}
+	case ListAppend:
+		return appendLists(dst, src), nil
+	case ListPrepend:
+		return appendLists(src, dst), nil
+	}
+	return dst, nil
+}
+
+// appendLists returns a new list holding the elements of a followed by the elements of b.
+func appendLists(a, b []interface{}) []interface{} {
+	l := make([]interface{}, 0, len(a)+len(b))
+	l = append(l, a...)
+	return append(l, b...)
 }
 
 // ReleaseOptions represents the additional release options needed
@@ -356,6 +2076,56 @@ type ReleaseOptions struct {
 	IsUpgrade bool
 	IsInstall bool
 	Revision  int
+	// Service is the name of the service performing the release. It
+	// defaults to "Tiller", which .Release.Service has always been, so that
+	// labels such as app.kubernetes.io/managed-by do not change.
+	Service string
+	// StrictMetadata makes ToRenderValues check the chart's metadata with
+	// ValidateMetadata before rendering.
+	StrictMetadata bool
+}
+
+// Validate checks that the release options are consistent.
+//
+// A release cannot be both an install and an upgrade. Leaving both unset is
+// allowed, since values are also rendered outside of a release, as when
+// linting a chart.
+func (o ReleaseOptions) Validate() error {
+	if o.IsInstall && o.IsUpgrade {
+		return errors.New("release options cannot be both an install and an upgrade")
+	}
+	return nil
+}
+
+// RenderMap returns the map of release information that templates see as
+// .Release, with the defaults filled in for unset fields.
+//
+// The namespace defaults to "default", and the service to "Tiller". An
+// install without a revision is the first revision.
+func (o ReleaseOptions) RenderMap() map[string]interface{} {
+	namespace := o.Namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	// An install is always the first revision of a release.
+	revision := o.Revision
+	if revision == 0 && o.IsInstall {
+		revision = 1
+	}
+	service := o.Service
+	if service == "" {
+		service = "Tiller"
+	}
+
+	return map[string]interface{}{
+		"Name":      o.Name,
+		"Time":      o.Time,
+		"Namespace": namespace,
+		"IsUpgrade": o.IsUpgrade,
+		"IsInstall": o.IsInstall,
+		"Revision":  revision,
+		"Service":   service,
+	}
 }
 
 // ToRenderValues composes the struct from the data coming from the Releases, Charts and Values files
@@ -373,20 +2143,27 @@ func ToRenderValues(chrt *chart.Chart, chrtVals *chart.Config, options ReleaseOp
 //
 // This takes both ReleaseOptions and Capabilities to merge into the render values.
 func ToRenderValuesCaps(chrt *chart.Chart, chrtVals *chart.Config, options ReleaseOptions, caps *Capabilities) (Values, error) {
+	if err := options.Validate(); err != nil {
+		return Values{}, err
+	}
+	if options.StrictMetadata {
+		if err := ValidateMetadata(chrt.Metadata); err != nil {
+			return Values{}, err
+		}
+	}
 
 	top := map[string]interface{}{
-		"Release": map[string]interface{}{
-			"Name":      options.Name,
-			"Time":      options.Time,
-			"Namespace": options.Namespace,
-			"IsUpgrade": options.IsUpgrade,
-			"IsInstall": options.IsInstall,
-			"Revision":  options.Revision,
-			"Service":   "Tiller",
-		},
+		"Release":      options.RenderMap(),
 		"Chart":        chrt.Metadata,
 		"Files":        NewFiles(chrt.Files),
 		"Capabilities": caps,
+		// The engine sets the Name of each template as it renders it. Outside
+		// of the engine no template is being rendered, but the base path is
+		// the same for all of the chart's templates.
+		"Template": map[string]interface{}{
+			"Name":     "",
+			"BasePath": path.Join(chrt.Metadata.Name, "templates"),
+		},
 	}
 
 	vals, err := CoalesceValues(chrt, chrtVals)
@@ -413,7 +2190,7 @@ func istable(v interface{}) bool {
 //	    title: "Loomings"
 func (v Values) PathValue(ypath string) (interface{}, error) {
 	if len(ypath) == 0 {
-		return nil, errors.New("YAML path string cannot be zero length")
+		return nil, &PathError{Path: ypath, Err: ErrEmptyPath}
 	}
 	yps := strings.Split(ypath, ".")
 	if len(yps) == 1 {
@@ -425,7 +2202,7 @@ func (v Values) PathValue(ypath string) (interface{}, error) {
 			return vals[yps[0]], nil
 		}
 		// key not found
-		return nil, ErrNoValue(fmt.Errorf("%v is not a value", k))
+		return nil, &PathError{Path: ypath, Segment: k, Err: missingValue(fmt.Sprintf("%v is not a value", k))}
 	}
 	// join all elements of YAML path except last to get string table path
 	ypsLen := len(yps)
@@ -438,7 +2215,10 @@ func (v Values) PathValue(ypath string) (interface{}, error) {
 	t, err := v.Table(st)
 	if err != nil {
 		//no table
-		return nil, ErrNoValue(fmt.Errorf("%v is not a value", sk))
+		if pe, ok := err.(*PathError); ok {
+			pe.Path = ypath
+		}
+		return nil, err
 	}
 	// check table for key and ensure value is not a table
 	if k, ok := t[sk]; ok && !istable(k) {
@@ -447,5 +2227,198 @@ func (v Values) PathValue(ypath string) (interface{}, error) {
 	}
 
 	// key not found
-	return nil, ErrNoValue(fmt.Errorf("key not found: %s", sk))
+	return nil, &PathError{Path: ypath, Segment: sk, Err: missingValue(fmt.Sprintf("key not found: %s", sk))}
+}
+
+// PathValueDefault returns the value at the end of a path like PathValue, or
+// def if PathValue would return an error. This includes a path whose value is
+// a table, and a path that runs into a missing key or a non-table value on
+// the way.
+//
+// A value that is explicitly null resolves, so it is returned as nil.
+func (v Values) PathValueDefault(ypath string, def interface{}) interface{} {
+	val, err := v.PathValue(ypath)
+	if err != nil {
+		return def
+	}
+	return val
+}
+
+// PathExists reports whether the given dotted path leads to a key in v.
+//
+// Unlike PathValue, the key may hold a table, and a key that is explicitly null
+// exists. A path that runs into a missing key or a non-table value on the way
+// does not exist, and neither does the empty path.
+func (v Values) PathExists(ypath string) bool {
+	if len(ypath) == 0 {
+		return false
+	}
+	_, ok := v.Dig(strings.Split(ypath, ".")...)
+	return ok
+}
+
+// GetString returns the string at the end of a path.
+//
+// Path errors are the same as for PathValue. An ErrWrongType is returned if the
+// value is not a string.
+func (v Values) GetString(ypath string) (string, error) {
+	val, err := v.PathValue(ypath)
+	if err != nil {
+		return "", err
+	}
+	s, ok := val.(string)
+	if !ok {
+		return "", ErrWrongType{Path: ypath, Type: "string", Value: val}
+	}
+	return s, nil
+}
+
+// GetBool returns the boolean at the end of a path.
+//
+// Path errors are the same as for PathValue. An ErrWrongType is returned if the
+// value is not a boolean.
+func (v Values) GetBool(ypath string) (bool, error) {
+	val, err := v.PathValue(ypath)
+	if err != nil {
+		return false, err
+	}
+	b, ok := val.(bool)
+	if !ok {
+		return false, ErrWrongType{Path: ypath, Type: "bool", Value: val}
+	}
+	return b, nil
+}
+
+// GetInt returns the integer at the end of a path.
+//
+// Numbers read from YAML are stored as float64, so any number without a
+// fractional part is accepted. Path errors are the same as
+// for PathValue. An ErrWrongType is returned for any other value, and for a
+// number outside the range of int on the platform.
+func (v Values) GetInt(ypath string) (int, error) {
+	val, err := v.PathValue(ypath)
+	if err != nil {
+		return 0, err
+	}
+	switch n := val.(type) {
+	case int:
+		return n, nil
+	case int64:
+		if n >= minInt && n <= maxInt {
+			return int(n), nil
+		}
+		return 0, ErrWrongType{Path: ypath, Type: "int", Value: val}
+	case json.Number:
+		if i, err := n.Int64(); err == nil {
+			if i >= minInt && i <= maxInt {
+				return int(i), nil
+			}
+			return 0, ErrWrongType{Path: ypath, Type: "int", Value: val}
+		}
+	}
+	// -minInt is a power of two, so it is exact as a float64, unlike maxInt.
+	f, ok := toFloat(val)
+	if !ok || f != math.Trunc(f) || f < minInt || f >= -float64(minInt) {
+		return 0, ErrWrongType{Path: ypath, Type: "int", Value: val}
+	}
+	return int(f), nil
+}
+
+// The range of int, which is 32 or 64 bits depending on the platform.
+const (
+	maxInt = 1<<(strconv.IntSize-1) - 1
+	minInt = -1 << (strconv.IntSize - 1)
+)
+
+// GetFloat returns the number at the end of a path as a float64.
+//
+// Path errors are the same as for PathValue. An ErrWrongType is returned if the
+// value is not a number.
+func (v Values) GetFloat(ypath string) (float64, error) {
+	val, err := v.PathValue(ypath)
+	if err != nil {
+		return 0, err
+	}
+	f, ok := toFloat(val)
+	if !ok {
+		return 0, ErrWrongType{Path: ypath, Type: "float", Value: val}
+	}
+	return f, nil
+}
+
+// toFloat converts any of the number types found in Values to a float64.
+func toFloat(val interface{}) (float64, bool) {
+	switch n := val.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// DeletePathValue takes a path that traverses a YAML structure and removes the
+// value at the end of that path. The value may be a table, in which case the
+// whole table is removed.
+//
+// An error is returned if any part of the path does not exist, or if a part of
+// the path before the last is not a table. Tables left empty by the deletion
+// are kept; use PrunePathValue to remove them as well.
+func (v Values) DeletePathValue(ypath string) error {
+	return v.deletePathValue(ypath, false)
+}
+
+// PrunePathValue removes the value at the end of a path like DeletePathValue,
+// and then removes every parent table that the deletion left empty.
+func (v Values) PrunePathValue(ypath string) error {
+	return v.deletePathValue(ypath, true)
+}
+
+func (v Values) deletePathValue(ypath string, prune bool) error {
+	if len(ypath) == 0 {
+		return &PathError{Path: ypath, Err: ErrEmptyPath}
+	}
+	yps := strings.Split(ypath, ".")
+
+	// tables[i] is the table holding the key yps[i].
+	tables := []map[string]interface{}{v}
+	for _, n := range yps[:len(yps)-1] {
+		val, ok := tables[len(tables)-1][n]
+		if !ok {
+			return &PathError{Path: ypath, Segment: n, Err: missingTable(fmt.Sprintf("no table named %q", n))}
+		}
+		var next map[string]interface{}
+		switch t := val.(type) {
+		case map[string]interface{}:
+			next = t
+		case Values:
+			next = t
+		default:
+			return &PathError{Path: ypath, Segment: n, Err: ErrNotTable{Key: n}}
+		}
+		tables = append(tables, next)
+	}
+
+	key := yps[len(yps)-1]
+	t := tables[len(tables)-1]
+	if _, ok := t[key]; !ok {
+		return &PathError{Path: ypath, Segment: key, Err: missingValue(fmt.Sprintf("key not found: %s", key))}
+	}
+	delete(t, key)
+
+	if prune {
+		for i := len(tables) - 1; i > 0 && len(tables[i]) == 0; i-- {
+			delete(tables[i-1], yps[i-1])
+		}
+	}
+	return nil
 }
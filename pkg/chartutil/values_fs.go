@@ -0,0 +1,33 @@
+//go:build go1.16
+// +build go1.16
+
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import "io/fs"
+
+// ReadValuesFileFS will parse a YAML file in the given file system into a map
+// of values. It is like ReadValuesFile, but reads through an fs.FS, such as an
+// embed.FS.
+func ReadValuesFileFS(fsys fs.FS, name string) (Values, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return map[string]interface{}{}, err
+	}
+	return ReadValues(data)
+}
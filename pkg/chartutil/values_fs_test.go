@@ -0,0 +1,53 @@
+//go:build go1.16
+// +build go1.16
+
+/*
+Copyright The Helm Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chartutil
+
+import (
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestReadValuesFileFS(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/coleridge.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{
+		"charts/coleridge/values.yaml": &fstest.MapFile{Data: data},
+	}
+
+	v, err := ReadValuesFileFS(fsys, "charts/coleridge/values.yaml")
+	if err != nil {
+		t.Fatalf("Error reading YAML file: %s", err)
+	}
+	expect, err := ReadValuesFile("testdata/coleridge.yaml")
+	if err != nil {
+		t.Fatalf("Error reading YAML file: %s", err)
+	}
+	if !reflect.DeepEqual(v, expect) {
+		t.Errorf("Expected %v, got %v", expect, v)
+	}
+
+	if _, err := ReadValuesFileFS(fsys, "charts/wordsworth/values.yaml"); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
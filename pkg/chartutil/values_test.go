@@ -18,12 +18,21 @@ package chartutil
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 	"text/template"
 
+	"github.com/ghodss/yaml"
 	"github.com/golang/protobuf/ptypes/any"
 
 	kversion "k8s.io/apimachinery/pkg/version"
@@ -73,6 +82,371 @@ water:
 	}
 }
 
+func TestReadValuesStringKeys(t *testing.T) {
+	doc := `
+poet: Coleridge
+1798: Lyrical Ballads
+stanzas:
+  - 1: It is an ancient Mariner
+    true: And he stoppeth one of three
+    lines:
+      1.5: By thy long grey beard
+mariner:
+  crew:
+    200: dead
+`
+	data, err := ReadValues([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var check func(path string, v interface{})
+	check = func(path string, v interface{}) {
+		switch vv := v.(type) {
+		case map[string]interface{}:
+			for k, val := range vv {
+				check(path+"."+k, val)
+			}
+		case []interface{}:
+			for i, val := range vv {
+				check(fmt.Sprintf("%s[%d]", path, i), val)
+			}
+		case string, nil, float64, bool:
+		default:
+			t.Errorf("%s: unexpected %T", path, v)
+		}
+	}
+	check("", map[string]interface{}(data))
+
+	if v, err := data.PathValue("mariner.crew.200"); err != nil || v != "dead" {
+		t.Errorf("Expected a number key to become a string, got %v (%v)", v, err)
+	}
+	stanza := data["stanzas"].([]interface{})[0].(map[string]interface{})
+	if stanza["true"] != "And he stoppeth one of three" {
+		t.Errorf("Expected a boolean key to become a string, got %v", stanza)
+	}
+
+	if _, err := ReadValues([]byte("? [a, b]\n: c\n")); err == nil {
+		t.Error("Expected an error for a list used as a key")
+	}
+}
+
+func TestReadValuesAliases(t *testing.T) {
+	doc := `
+defaults: &defaults
+  image:
+    tag: v1
+web: *defaults
+worker: *defaults
+`
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "moby"},
+		Values:   &chart.Config{Raw: doc},
+	}
+	v, err := CoalesceValues(c, &chart.Config{Raw: "web:\n  image:\n    tag: v2\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag, _ := v.PathValue("web.image.tag"); tag != "v2" {
+		t.Errorf("Expected web.image.tag to be overridden, got %v", tag)
+	}
+	for _, p := range []string{"defaults.image.tag", "worker.image.tag"} {
+		if tag, _ := v.PathValue(p); tag != "v1" {
+			t.Errorf("Expected %s to be unchanged, got %v", p, tag)
+		}
+	}
+
+	vals, err := ReadValues([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vals["web"].(map[string]interface{})["image"].(map[string]interface{})["tag"] = "v3"
+	if tag, _ := vals.PathValue("worker.image.tag"); tag != "v1" {
+		t.Errorf("Expected worker.image.tag to be independent of web, got %v", tag)
+	}
+}
+
+func TestReadValuesStrict(t *testing.T) {
+	doc := `poet: "Coleridge"
+title: "Rime of the Ancient Mariner"
+poet: "Wordsworth"
+`
+
+	if _, err := ReadValuesStrict([]byte(doc)); err == nil {
+		t.Error("Expected an error for the duplicated key")
+	} else if !strings.Contains(err.Error(), `"poet"`) {
+		t.Errorf("Expected the error to name the duplicated key, got %s", err)
+	}
+
+	// ReadValues is lenient, and keeps the last value.
+	data, err := ReadValues([]byte(doc))
+	if err != nil {
+		t.Fatalf("Error parsing bytes: %s", err)
+	}
+	if data["poet"] != "Wordsworth" {
+		t.Errorf("Unexpected poet: %s", data["poet"])
+	}
+
+	data, err = ReadValuesStrict([]byte(`poet: "Coleridge"`))
+	if err != nil {
+		t.Fatalf("Error parsing bytes: %s", err)
+	}
+	if data["poet"] != "Coleridge" {
+		t.Errorf("Unexpected poet: %s", data["poet"])
+	}
+}
+
+func TestReadValuesJSON(t *testing.T) {
+	doc := `{"poet": "Coleridge", "id": 9007199254740993, "ship": {"masts": 3, "crew": [{"age": 30.5}, 18446744073709551616]}}`
+
+	data, err := ReadValuesJSON([]byte(doc))
+	if err != nil {
+		t.Fatalf("Error parsing bytes: %s", err)
+	}
+	if data["poet"] != "Coleridge" {
+		t.Errorf("Unexpected poet: %s", data["poet"])
+	}
+	if id := data["id"]; id != int64(9007199254740993) {
+		t.Errorf("Expected id 9007199254740993, got %v (%T)", id, id)
+	}
+	ship := data["ship"].(map[string]interface{})
+	if masts := ship["masts"]; masts != int64(3) {
+		t.Errorf("Expected 3 masts, got %v (%T)", masts, masts)
+	}
+	crew := ship["crew"].([]interface{})
+	if age := crew[0].(map[string]interface{})["age"]; age != 30.5 {
+		t.Errorf("Expected age 30.5, got %v (%T)", age, age)
+	}
+	if big := crew[1]; big != float64(1<<64) {
+		t.Errorf("Expected a float64 for a number too large for an int64, got %v (%T)", big, big)
+	}
+
+	// 9007199254740993 cannot be represented as a float64, so it does not
+	// survive ReadValues.
+	yvals, err := ReadValues([]byte(doc))
+	if err != nil {
+		t.Fatalf("Error parsing bytes: %s", err)
+	}
+	if id := fmt.Sprintf("%.0f", yvals["id"]); id == "9007199254740993" {
+		t.Errorf("Expected ReadValues to lose precision, got %s", id)
+	}
+
+	for _, tt := range []string{`{}`, ""} {
+		data, err = ReadValuesJSON([]byte(tt))
+		if err != nil {
+			t.Fatalf("Error parsing bytes (%s): %s", tt, err)
+		}
+		if data == nil {
+			t.Errorf(`JSON string "%s" gave a nil map`, tt)
+		}
+	}
+
+	for _, tt := range []string{`{} {}`, `{"a": 1} x`, `[1]`} {
+		if _, err := ReadValuesJSON([]byte(tt)); err == nil {
+			t.Errorf("Expected an error for %s", tt)
+		}
+	}
+}
+
+func TestReadValuesDoc(t *testing.T) {
+	doc := `poet: "Coleridge"
+title: "Rime of the Ancient Mariner"
+---
+poet: "Wordsworth"
+title: "Lyrical Ballads"
+---
+# An empty document
+`
+
+	tests := []struct {
+		index int
+		poet  interface{}
+	}{
+		{0, "Coleridge"},
+		{1, "Wordsworth"},
+		{2, nil},
+	}
+	for _, tt := range tests {
+		data, err := ReadValuesDoc([]byte(doc), tt.index)
+		if err != nil {
+			t.Fatalf("Error reading document %d: %s", tt.index, err)
+		}
+		if data == nil {
+			t.Fatalf("Document %d gave a nil map", tt.index)
+		}
+		if data["poet"] != tt.poet {
+			t.Errorf("Document %d: expected poet %v, got %v", tt.index, tt.poet, data["poet"])
+		}
+	}
+
+	for _, index := range []int{-1, 3} {
+		if _, err := ReadValuesDoc([]byte(doc), index); err == nil {
+			t.Errorf("Expected an error reading document %d", index)
+		}
+	}
+}
+
+func TestReadValuesTOML(t *testing.T) {
+	tomlDoc := `
+poet = "Coleridge"
+stanzas = 143
+
+[mariner]
+with = "crossbow"
+shot = "ALBATROSS"
+
+[[mariner.crew]]
+name = "helmsman"
+
+[[mariner.crew]]
+name = "bosun"
+`
+	yamlDoc := `
+poet: Coleridge
+stanzas: 143
+mariner:
+  with: crossbow
+  shot: ALBATROSS
+  crew:
+  - name: helmsman
+  - name: bosun
+`
+
+	tv, err := ReadValuesTOML([]byte(tomlDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	yv, err := ReadValues([]byte(yamlDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tv, yv) {
+		t.Errorf("Expected %v, got %v", yv, tv)
+	}
+
+	if v, err := ReadValuesTOML(nil); err != nil || len(v) != 0 {
+		t.Errorf("Expected empty values for empty input, got %v (%v)", v, err)
+	}
+	if _, err := ReadValuesTOML([]byte("poet = ")); err == nil {
+		t.Error("Expected an error for invalid TOML")
+	}
+}
+
+func TestReadValuesCoerce(t *testing.T) {
+	doc := `
+debug: "true"
+verbose: "false"
+port: "8080"
+offset: "-1"
+zip: "08080"
+plus: "+1"
+ratio: "0.5"
+answer: "yes"
+name: moby
+hosts:
+- "443"
+- example.com
+`
+	v, err := ReadValuesCoerce([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := Values{
+		"debug":   true,
+		"verbose": false,
+		"port":    8080,
+		"offset":  -1,
+		"zip":     "08080",
+		"plus":    "+1",
+		"ratio":   "0.5",
+		"answer":  "yes",
+		"name":    "moby",
+		"hosts":   []interface{}{443, "example.com"},
+	}
+	if !reflect.DeepEqual(v, expect) {
+		t.Errorf("Expected %v, got %v", expect, v)
+	}
+
+	plain, err := ReadValues([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain["debug"] != "true" || plain["port"] != "8080" {
+		t.Errorf("Expected ReadValues to keep strings, got %v and %v", plain["debug"], plain["port"])
+	}
+}
+
+func TestReadValuesLimited(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/coleridge.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The Coleridge fixture has 10 keys, counting the nested ones.
+	if _, err := ReadValuesLimited(data, 100, 0); err == nil {
+		t.Error("Expected an error for data over the byte limit")
+	}
+	if _, err := ReadValuesLimited(data, 0, 9); err == nil {
+		t.Error("Expected an error for values over the key limit")
+	}
+
+	v, err := ReadValuesLimited(data, 1<<20, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, err := ReadValues(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, expect) {
+		t.Errorf("Expected %v, got %v", expect, v)
+	}
+
+	if _, err := ReadValuesLimited(data, 0, 0); err != nil {
+		t.Errorf("Expected no limits, got %v", err)
+	}
+}
+
+func TestReadValuesMerged(t *testing.T) {
+	doc := `poet: "Coleridge"
+title: "Rime of the Ancient Mariner"
+mariner:
+  with: "crossbow"
+  shot: "ALBATROSS"
+stanza: ["at", "length"]
+---
+title: "The Rime of the Ancyent Marinere"
+mariner:
+  with: "longbow"
+stanza: ["did", "cross"]
+---
+# An empty document
+`
+	data, err := ReadValuesMerged([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := Values{
+		"poet":  "Coleridge",
+		"title": "The Rime of the Ancyent Marinere",
+		"mariner": map[string]interface{}{
+			"with": "longbow",
+			"shot": "ALBATROSS",
+		},
+		"stanza": []interface{}{"did", "cross"},
+	}
+	if !reflect.DeepEqual(data, expect) {
+		t.Errorf("Expected %v, got %v", expect, data)
+	}
+
+	if data, err := ReadValuesMerged(nil); err != nil || data == nil || len(data) != 0 {
+		t.Errorf("Expected empty values for no documents, got %v (%v)", data, err)
+	}
+	if _, err := ReadValuesMerged([]byte("poet: Coleridge\n---\n- stanza\n")); err == nil {
+		t.Error("Expected an error for a document that is not a table")
+	}
+}
+
 func TestToRenderValuesCaps(t *testing.T) {
 
 	chartValues := `
@@ -131,6 +505,9 @@ where:
 	if name := relmap["Name"]; name.(string) != "Seven Voyages" {
 		t.Errorf("Expected release name 'Seven Voyages', got %q", name)
 	}
+	if ns := relmap["Namespace"]; ns.(string) != "al Basrah" {
+		t.Errorf("Expected release namespace 'al Basrah', got %q", ns)
+	}
 	if rev := relmap["Revision"]; rev.(int) != 5 {
 		t.Errorf("Expected release revision %d, got %q", 5, rev)
 	}
@@ -140,6 +517,14 @@ where:
 	if !relmap["IsInstall"].(bool) {
 		t.Errorf("Expected install to be true.")
 	}
+	if svc := relmap["Service"]; svc.(string) != "Tiller" {
+		t.Errorf("Expected release service %q, got %q", "Tiller", svc)
+	}
+	if tpl, ok := res["Template"].(map[string]interface{}); !ok {
+		t.Error("Expected a Template in the render values")
+	} else if tpl["Name"] != "" || tpl["BasePath"] != "test/templates" {
+		t.Errorf("Expected no template name and the base path test/templates, got %v", tpl)
+	}
 	if data := res["Files"].(Files)["scheherazade/shahryar.txt"]; string(data) != "1,001 Nights" {
 		t.Errorf("Expected file '1,001 Nights', got %q", string(data))
 	}
@@ -172,92 +557,601 @@ where:
 	}
 }
 
-func TestReadValuesFile(t *testing.T) {
-	data, err := ReadValuesFile("./testdata/coleridge.yaml")
-	if err != nil {
-		t.Fatalf("Error reading YAML file: %s", err)
+func TestToRenderValuesInvalidOptions(t *testing.T) {
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "test"},
+		Values:   &chart.Config{Raw: "name: al Rashid"},
+	}
+	o := ReleaseOptions{
+		Name:      "Seven Voyages",
+		IsInstall: true,
+		IsUpgrade: true,
 	}
-	matchValues(t, data)
-}
 
-func ExampleValues() {
-	doc := `
-title: "Moby Dick"
-chapter:
-  one:
-    title: "Loomings"
-  two:
-    title: "The Carpet-Bag"
-  three:
-    title: "The Spouter Inn"
-`
-	d, err := ReadValues([]byte(doc))
-	if err != nil {
-		panic(err)
+	if _, err := ToRenderValues(c, &chart.Config{}, o); err == nil {
+		t.Error("Expected an error for a release that is both an install and an upgrade")
 	}
-	ch1, err := d.Table("chapter.one")
-	if err != nil {
-		panic("could not find chapter one")
+
+	o.IsUpgrade = false
+	if err := o.Validate(); err != nil {
+		t.Errorf("Expected an install to be valid: %s", err)
 	}
-	fmt.Print(ch1["title"])
-	// Output:
-	// Loomings
 }
 
-func TestTable(t *testing.T) {
-	doc := `
-title: "Moby Dick"
-chapter:
-  one:
-    title: "Loomings"
-  two:
-    title: "The Carpet-Bag"
-  three:
-    title: "The Spouter Inn"
-`
-	d, err := ReadValues([]byte(doc))
-	if err != nil {
-		t.Fatalf("Failed to parse the White Whale: %s", err)
+func TestToRenderValuesRevision(t *testing.T) {
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "test"},
+		Values:   &chart.Config{Raw: "name: al Rashid"},
 	}
 
-	if _, err := d.Table("title"); err == nil {
-		t.Fatalf("Title is not a table.")
+	tests := []struct {
+		options ReleaseOptions
+		expect  int
+	}{
+		{ReleaseOptions{Name: "Seven Voyages", IsInstall: true}, 1},
+		{ReleaseOptions{Name: "Seven Voyages", IsUpgrade: true, Revision: 3}, 3},
 	}
-
-	if _, err := d.Table("chapter"); err != nil {
-		t.Fatalf("Failed to get the chapter table: %s\n%v", err, d)
+	for _, tt := range tests {
+		res, err := ToRenderValues(c, &chart.Config{}, tt.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		relmap := res["Release"].(map[string]interface{})
+		if rev := relmap["Revision"]; rev.(int) != tt.expect {
+			t.Errorf("Expected release revision %d, got %v", tt.expect, rev)
+		}
 	}
+}
 
-	if v, err := d.Table("chapter.one"); err != nil {
-		t.Errorf("Failed to get chapter.one: %s", err)
-	} else if v["title"] != "Loomings" {
-		t.Errorf("Unexpected title: %s", v["title"])
+func TestToRenderValuesStrictMetadata(t *testing.T) {
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "test"},
+		Values:   &chart.Config{Raw: "name: al Rashid"},
 	}
 
-	if _, err := d.Table("chapter.three"); err != nil {
-		t.Errorf("Chapter three is missing: %s\n%v", err, d)
+	if _, err := ToRenderValues(c, &chart.Config{}, ReleaseOptions{Name: "Seven Voyages"}); err != nil {
+		t.Errorf("Expected metadata not to be checked by default, got %s", err)
 	}
-
-	if _, err := d.Table("chapter.OneHundredThirtySix"); err == nil {
-		t.Errorf("I think you mean 'Epilogue'")
+	_, err := ToRenderValues(c, &chart.Config{}, ReleaseOptions{Name: "Seven Voyages", StrictMetadata: true})
+	if err == nil || !strings.Contains(err.Error(), "version must not be empty") {
+		t.Errorf("Expected a missing version error, got %v", err)
 	}
 }
 
-func matchValues(t *testing.T, data map[string]interface{}) {
-	if data["poet"] != "Coleridge" {
-		t.Errorf("Unexpected poet: %s", data["poet"])
+func TestReleaseOptionsRenderMap(t *testing.T) {
+	now := timeconv.Now()
+	o := ReleaseOptions{
+		Name:      "Seven Voyages",
+		Time:      now,
+		Namespace: "baghdad",
+		IsInstall: true,
 	}
 
-	if o, err := ttpl("{{len .stanza}}", data); err != nil {
-		t.Errorf("len stanza: %s", err)
-	} else if o != "6" {
-		t.Errorf("Expected 6, got %s", o)
+	expect := map[string]interface{}{
+		"Name":      "Seven Voyages",
+		"Time":      now,
+		"Namespace": "baghdad",
+		"IsUpgrade": false,
+		"IsInstall": true,
+		"Revision":  1,
+		"Service":   "Tiller",
 	}
-
-	if o, err := ttpl("{{.mariner.shot}}", data); err != nil {
-		t.Errorf(".mariner.shot: %s", err)
-	} else if o != "ALBATROSS" {
-		t.Errorf("Expected that mariner shot ALBATROSS")
+	if m := o.RenderMap(); !reflect.DeepEqual(m, expect) {
+		t.Errorf("Expected %v, got %v", expect, m)
+	}
+}
+
+func TestToRenderValuesService(t *testing.T) {
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "test"},
+		Values:   &chart.Config{Raw: "name: al Rashid"},
+	}
+
+	tests := []struct {
+		options ReleaseOptions
+		expect  string
+	}{
+		{ReleaseOptions{Name: "Seven Voyages", IsInstall: true}, "Tiller"},
+		{ReleaseOptions{Name: "Seven Voyages", IsInstall: true, Service: "Helm"}, "Helm"},
+	}
+	for _, tt := range tests {
+		res, err := ToRenderValues(c, &chart.Config{}, tt.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		relmap := res["Release"].(map[string]interface{})
+		if svc := relmap["Service"]; svc.(string) != tt.expect {
+			t.Errorf("Expected release service %q, got %q", tt.expect, svc)
+		}
+	}
+}
+
+func TestToRenderValuesDefaultNamespace(t *testing.T) {
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "test"},
+		Values:   &chart.Config{Raw: "name: al Rashid"},
+	}
+
+	res, err := ToRenderValues(c, &chart.Config{}, ReleaseOptions{Name: "Seven Voyages", IsInstall: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	relmap := res["Release"].(map[string]interface{})
+	if ns := relmap["Namespace"]; ns.(string) != "default" {
+		t.Errorf("Expected release namespace 'default', got %q", ns)
+	}
+}
+
+func TestReadValuesFile(t *testing.T) {
+	data, err := ReadValuesFile("./testdata/coleridge.yaml")
+	if err != nil {
+		t.Fatalf("Error reading YAML file: %s", err)
+	}
+	matchValues(t, data)
+}
+
+func TestReadValuesReader(t *testing.T) {
+	data, err := ioutil.ReadFile("./testdata/coleridge.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := []string{
+		string(data),
+		"port: 8080\nratio: 0.5\n1: one\ntrue: yes\nlist:\n- a: 1\n",
+		"1.5: float\n1e3: exponent\n0x1F: hex\n-7: negative\nfalse: bool\n",
+		"port: 8080\nnested:\n  2.0: two\n  on: bool\n  3: [1.0, 2.5, 1e3, 9223372036854775807]\n",
+		"",
+		"# just a comment\n",
+	}
+	for _, doc := range docs {
+		expect, err := ReadValues([]byte(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadValuesReader(strings.NewReader(doc))
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %s", doc, err)
+		}
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("Expected %v, got %v", expect, got)
+		}
+	}
+
+	if _, err := ReadValuesReader(strings.NewReader("poet: [Coleridge")); err == nil {
+		t.Error("Expected an error for invalid YAML")
+	}
+	if _, err := ReadValuesReader(strings.NewReader("Coleridge")); err == nil {
+		t.Error("Expected an error for a document that is not a table")
+	}
+}
+
+func TestMergeValueFiles(t *testing.T) {
+	files := [][]byte{
+		[]byte("name: moby\nwhere:\n  city: Nantucket\n  title: whaler\ncaptain: Ahab\n"),
+		[]byte("where:\n  title: ship\ncaptain: null\n"),
+		[]byte("name: pequod\n"),
+	}
+	v, err := MergeValueFiles(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := Values{
+		"name": "pequod",
+		"where": map[string]interface{}{
+			"city":  "Nantucket",
+			"title": "ship",
+		},
+		"captain": nil,
+	}
+	if !reflect.DeepEqual(v, expect) {
+		t.Errorf("Expected %v, got %v", expect, v)
+	}
+
+	// The null still removes the chart default once coalesced.
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "moby"},
+		Values:   &chart.Config{Raw: "captain: Ahab\n"},
+	}
+	cv, err := CoalesceValuesWithOptions(c, v, CoalesceValuesOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cv["captain"]; ok {
+		t.Error("Expected captain to be removed when coalesced")
+	}
+
+	if _, err := MergeValueFiles([][]byte{[]byte("name: moby\n"), []byte("name: [moby")}); err == nil {
+		t.Error("Expected an error for an invalid file")
+	}
+	if v, err := MergeValueFiles(nil); err != nil || len(v) != 0 {
+		t.Errorf("Expected empty values for no files, got %v (%v)", v, err)
+	}
+}
+
+func TestValuesYAML(t *testing.T) {
+	data, err := ReadValuesFile("./testdata/coleridge.yaml")
+	if err != nil {
+		t.Fatalf("Error reading YAML file: %s", err)
+	}
+	data["albatross"] = nil
+	data["moral"] = ""
+
+	out, err := data.YAML()
+	if err != nil {
+		t.Fatalf("Error encoding YAML: %s", err)
+	}
+	again, err := data.YAML()
+	if err != nil {
+		t.Fatalf("Error encoding YAML: %s", err)
+	}
+	if out != again {
+		t.Errorf("Expected identical output, got\n%s\nand\n%s", out, again)
+	}
+	if !strings.HasPrefix(out, "albatross: null\nmariner:\n") {
+		t.Errorf("Expected sorted keys, got\n%s", out)
+	}
+
+	read, err := ReadValues([]byte(out))
+	if err != nil {
+		t.Fatalf("Error parsing YAML output: %s", err)
+	}
+	if !reflect.DeepEqual(data, read) {
+		t.Errorf("Expected %v, got %v", data, read)
+	}
+}
+
+func TestValuesJSON(t *testing.T) {
+	data, err := ReadValuesFile("./testdata/coleridge.yaml")
+	if err != nil {
+		t.Fatalf("Error reading YAML file: %s", err)
+	}
+
+	out, err := data.JSON()
+	if err != nil {
+		t.Fatalf("Error encoding JSON: %s", err)
+	}
+	if !json.Valid(out) {
+		t.Fatalf("Expected valid JSON, got %s", out)
+	}
+	if !strings.HasPrefix(string(out), `{"mariner":{"shot":"ALBATROSS","with":"crossbow"},"poet":`) {
+		t.Errorf("Expected sorted keys, got %s", out)
+	}
+	var read map[string]interface{}
+	if err := json.Unmarshal(out, &read); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(map[string]interface{}(data), read) {
+		t.Errorf("Expected %v, got %v", data, read)
+	}
+
+	// Tables decoded straight from a YAML library have interface{} keys.
+	v := Values{
+		"ship": map[interface{}]interface{}{
+			"name": "Pequod",
+			"crew": []interface{}{map[interface{}]interface{}{1: "Ahab", true: "Starbuck"}},
+		},
+	}
+	out, err = v.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := `{"ship":{"crew":[{"1":"Ahab","true":"Starbuck"}],"name":"Pequod"}}`; string(out) != expect {
+		t.Errorf("Expected %s, got %s", expect, out)
+	}
+
+	v = Values{"ship": map[interface{}]interface{}{struct{}{}: "Pequod"}}
+	if _, err := v.JSON(); err == nil {
+		t.Error("Expected an error for a key that is not a string, number or boolean")
+	}
+}
+
+func TestValuesEncode(t *testing.T) {
+	data, err := ReadValuesFile("./testdata/coleridge.yaml")
+	if err != nil {
+		t.Fatalf("Error reading YAML file: %s", err)
+	}
+	jsonVals, err := ReadValuesJSON([]byte(`{"port": 8080, "ratio": 0.5, "id": 9007199254740993}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []Values{
+		data,
+		jsonVals,
+		{
+			"albatross": nil,
+			"moral":     "",
+			"count":     25,
+			"ratio":     0.5,
+			"yes":       "yes",
+			"empty":     map[string]interface{}{},
+			"crew":      []interface{}{map[string]interface{}{"b": 1, "a": "2"}, true},
+			"nested":    Values{"z": "last", "a": "first"},
+		},
+		{},
+	} {
+		var b bytes.Buffer
+		if err := v.Encode(&b); err != nil {
+			t.Fatalf("Error encoding YAML: %s", err)
+		}
+		expect, err := yaml.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != string(expect) {
+			t.Errorf("Expected Encode to write\n%s\ngot\n%s", expect, b.String())
+		}
+	}
+
+	if err := data.Encode(failingWriter{}); err == nil {
+		t.Error("Expected the writer's error to be returned")
+	}
+
+	// Whole floats, as ReadValues produces for every number, are written as
+	// integers rather than in exponent form, and read back unchanged.
+	floats := Values{"tons": float64(1000000), "ratio": 0.5, "huge": 1e300}
+	var b bytes.Buffer
+	if err := floats.Encode(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "tons: 1000000\n") {
+		t.Errorf("Expected tons to be written as an integer, got\n%s", b.String())
+	}
+	back, err := ReadValues(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(back, floats) {
+		t.Errorf("Expected %v to round trip, got %v", floats, back)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestValuesMerge(t *testing.T) {
+	base := Values{
+		"name": "moby",
+		"where": map[string]interface{}{
+			"city":  "Nantucket",
+			"title": "whaler",
+		},
+		"crew": []interface{}{"Ahab", "Starbuck"},
+	}
+	overrides := Values{
+		"where": map[string]interface{}{
+			"city": "New Bedford",
+		},
+		"crew": []interface{}{"Ishmael"},
+	}
+
+	v := base.Merge(overrides)
+	expect := Values{
+		"name": "moby",
+		"where": map[string]interface{}{
+			"city":  "New Bedford",
+			"title": "whaler",
+		},
+		"crew": []interface{}{"Ishmael"},
+	}
+	if !reflect.DeepEqual(v, expect) {
+		t.Errorf("Expected %v, got %v", expect, v)
+	}
+
+	if city := base["where"].(map[string]interface{})["city"]; city != "Nantucket" {
+		t.Errorf("Expected base where.city to be unchanged, got %v", city)
+	}
+	if _, ok := overrides["name"]; ok {
+		t.Error("Expected overrides to be unchanged")
+	}
+
+	// Changing the type of a value is not worth a warning, and a null is kept.
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	v = base.Merge(Values{"where": "at sea", "name": nil})
+	if v["where"] != "at sea" {
+		t.Errorf("Expected where to be replaced, got %v", v["where"])
+	}
+	if name, ok := v["name"]; !ok || name != nil {
+		t.Errorf("Expected name to be kept as null, got %v", name)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("Expected no warnings, got %q", logs.String())
+	}
+	if v := Values(nil).Merge(Values{"name": "moby"}); v["name"] != "moby" {
+		t.Errorf("Expected the overrides for nil values, got %v", v)
+	}
+}
+
+func TestValuesDeepCopy(t *testing.T) {
+	orig := Values{
+		"poet": "Coleridge",
+		"mariner": map[string]interface{}{
+			"with": "crossbow",
+		},
+		"crew": []interface{}{
+			map[string]interface{}{"name": "helmsman"},
+			"bosun",
+		},
+	}
+
+	cp := orig.DeepCopy()
+	if !reflect.DeepEqual(orig, cp) {
+		t.Fatalf("Expected %v, got %v", orig, cp)
+	}
+
+	cp["poet"] = "Wordsworth"
+	cp["mariner"].(map[string]interface{})["with"] = "harpoon"
+	crew := cp["crew"].([]interface{})
+	crew[0].(map[string]interface{})["name"] = "cook"
+	crew[1] = "cabin boy"
+
+	if orig["poet"] != "Coleridge" {
+		t.Errorf("Expected poet to be unchanged, got %v", orig["poet"])
+	}
+	if w := orig["mariner"].(map[string]interface{})["with"]; w != "crossbow" {
+		t.Errorf("Expected mariner.with to be unchanged, got %v", w)
+	}
+	origCrew := orig["crew"].([]interface{})
+	if n := origCrew[0].(map[string]interface{})["name"]; n != "helmsman" {
+		t.Errorf("Expected crew[0].name to be unchanged, got %v", n)
+	}
+	if origCrew[1] != "bosun" {
+		t.Errorf("Expected crew[1] to be unchanged, got %v", origCrew[1])
+	}
+
+	var empty Values
+	if empty.DeepCopy() != nil {
+		t.Error("Expected a nil copy of nil Values")
+	}
+}
+
+func ExampleValues() {
+	doc := `
+title: "Moby Dick"
+chapter:
+  one:
+    title: "Loomings"
+  two:
+    title: "The Carpet-Bag"
+  three:
+    title: "The Spouter Inn"
+`
+	d, err := ReadValues([]byte(doc))
+	if err != nil {
+		panic(err)
+	}
+	ch1, err := d.Table("chapter.one")
+	if err != nil {
+		panic("could not find chapter one")
+	}
+	fmt.Print(ch1["title"])
+	// Output:
+	// Loomings
+}
+
+func TestTable(t *testing.T) {
+	doc := `
+title: "Moby Dick"
+chapter:
+  one:
+    title: "Loomings"
+  two:
+    title: "The Carpet-Bag"
+  three:
+    title: "The Spouter Inn"
+`
+	d, err := ReadValues([]byte(doc))
+	if err != nil {
+		t.Fatalf("Failed to parse the White Whale: %s", err)
+	}
+
+	if _, err := d.Table("title"); err == nil {
+		t.Fatalf("Title is not a table.")
+	}
+
+	if _, err := d.Table("chapter"); err != nil {
+		t.Fatalf("Failed to get the chapter table: %s\n%v", err, d)
+	}
+
+	if v, err := d.Table("chapter.one"); err != nil {
+		t.Errorf("Failed to get chapter.one: %s", err)
+	} else if v["title"] != "Loomings" {
+		t.Errorf("Unexpected title: %s", v["title"])
+	}
+
+	if _, err := d.Table("chapter.three"); err != nil {
+		t.Errorf("Chapter three is missing: %s\n%v", err, d)
+	}
+
+	if _, err := d.Table("chapter.OneHundredThirtySix"); err == nil {
+		t.Errorf("I think you mean 'Epilogue'")
+	}
+}
+
+func TestCheckMaxStringLength(t *testing.T) {
+	d, err := ReadValuesFile("./testdata/coleridge.yaml")
+	if err != nil {
+		t.Fatalf("Error reading YAML file: %s", err)
+	}
+
+	if got := d.CheckMaxStringLength(100); len(got) != 0 {
+		t.Errorf("Expected no violations, got %v", got)
+	}
+
+	expect := []string{
+		"title: length 27 exceeds the maximum of 10",
+		"water.water.nor: length 17 exceeds the maximum of 10",
+	}
+	if got := d.CheckMaxStringLength(10); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expected %v, got %v", expect, got)
+	}
+
+	expect = []string{"stanza[5]: length 9 exceeds the maximum of 8"}
+	if got := (Values{"stanza": d["stanza"]}).CheckMaxStringLength(8); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expected %v, got %v", expect, got)
+	}
+}
+
+func TestCheckGatedConsistency(t *testing.T) {
+	doc := `
+tls:
+  enabled: false
+  certFile: /etc/tls/cert.pem
+ingress:
+  enabled: true
+  hosts: []
+metrics:
+  enabled: true
+  port: 9090
+persistence:
+  enabled: false
+  size: ""
+debug:
+  enabled: "yes"
+`
+	d, err := ReadValues([]byte(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gates := []Gate{
+		{Path: "tls.enabled", Subtree: "tls"},
+		{Path: "ingress.enabled", Subtree: "ingress"},
+		{Path: "metrics.enabled", Subtree: "metrics"},
+		{Path: "persistence.enabled", Subtree: "persistence"},
+		{Path: "debug.enabled", Subtree: "debug"},
+		{Path: "auth.enabled", Subtree: "auth"},
+	}
+	expect := []string{
+		"tls: values are set, but tls.enabled is not true",
+		"ingress: no values are set, but ingress.enabled is true",
+		"debug.enabled: gate is not a boolean: yes",
+	}
+	if got := d.CheckGatedConsistency(gates); !reflect.DeepEqual(expect, got) {
+		t.Errorf("Expected %v, got %v", expect, got)
+	}
+}
+
+func matchValues(t *testing.T, data map[string]interface{}) {
+	if data["poet"] != "Coleridge" {
+		t.Errorf("Unexpected poet: %s", data["poet"])
+	}
+
+	if o, err := ttpl("{{len .stanza}}", data); err != nil {
+		t.Errorf("len stanza: %s", err)
+	} else if o != "6" {
+		t.Errorf("Expected 6, got %s", o)
+	}
+
+	if o, err := ttpl("{{.mariner.shot}}", data); err != nil {
+		t.Errorf(".mariner.shot: %s", err)
+	} else if o != "ALBATROSS" {
+		t.Errorf("Expected that mariner shot ALBATROSS")
 	}
 
 	if o, err := ttpl("{{.water.water.where}}", data); err != nil {
@@ -267,162 +1161,1656 @@ func matchValues(t *testing.T, data map[string]interface{}) {
 	}
 }
 
-func ttpl(tpl string, v map[string]interface{}) (string, error) {
-	var b bytes.Buffer
-	tt := template.Must(template.New("t").Parse(tpl))
-	if err := tt.Execute(&b, v); err != nil {
-		return "", err
+func ttpl(tpl string, v map[string]interface{}) (string, error) {
+	var b bytes.Buffer
+	tt := template.Must(template.New("t").Parse(tpl))
+	if err := tt.Execute(&b, v); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// ref: http://www.yaml.org/spec/1.2/spec.html#id2803362
+var testCoalesceValuesYaml = `
+top: yup
+bottom: null
+right: Null
+left: NULL
+front: ~
+back: ""
+
+global:
+  name: Ishmael
+  subject: Queequeg
+  nested:
+    boat: true
+
+pequod:
+  global:
+    name: Stinky
+    harpooner: Tashtego
+    nested:
+      boat: false
+      sail: true
+  ahab:
+    scope: whale
+`
+
+func TestCoalesceValues(t *testing.T) {
+	tchart := "testdata/moby"
+	c, err := LoadDir(tchart)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tvals := &chart.Config{Raw: testCoalesceValuesYaml}
+
+	v, err := CoalesceValues(c, tvals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, _ := json.MarshalIndent(v, "", "  ")
+	t.Logf("Coalesced Values: %s", string(j))
+
+	tests := []struct {
+		tpl    string
+		expect string
+	}{
+		{"{{.top}}", "yup"},
+		{"{{.back}}", ""},
+		{"{{.name}}", "moby"},
+		{"{{.global.name}}", "Ishmael"},
+		{"{{.global.subject}}", "Queequeg"},
+		{"{{.global.harpooner}}", "<no value>"},
+		{"{{.pequod.name}}", "pequod"},
+		{"{{.pequod.ahab.name}}", "ahab"},
+		{"{{.pequod.ahab.scope}}", "whale"},
+		{"{{.pequod.ahab.global.name}}", "Ishmael"},
+		{"{{.pequod.ahab.global.subject}}", "Queequeg"},
+		{"{{.pequod.ahab.global.harpooner}}", "Tashtego"},
+		{"{{.pequod.global.name}}", "Ishmael"},
+		{"{{.pequod.global.subject}}", "Queequeg"},
+		{"{{.spouter.global.name}}", "Ishmael"},
+		{"{{.spouter.global.harpooner}}", "<no value>"},
+
+		{"{{.global.nested.boat}}", "true"},
+		{"{{.pequod.global.nested.boat}}", "true"},
+		{"{{.spouter.global.nested.boat}}", "true"},
+		{"{{.pequod.global.nested.sail}}", "true"},
+		{"{{.spouter.global.nested.sail}}", "<no value>"},
+	}
+
+	for _, tt := range tests {
+		if o, err := ttpl(tt.tpl, v); err != nil || o != tt.expect {
+			t.Errorf("Expected %q to expand to %q, got %q", tt.tpl, tt.expect, o)
+		}
+	}
+
+	nullKeys := []string{"bottom", "right", "left", "front"}
+	for _, nullKey := range nullKeys {
+		if _, ok := v[nullKey]; ok {
+			t.Errorf("Expected key %q to be removed, still present", nullKey)
+		}
+	}
+}
+
+func TestMergeValues(t *testing.T) {
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "moby"},
+		Values: &chart.Config{Raw: `
+name: moby
+where:
+  city: Nantucket
+  title: whaler
+`},
+	}
+	overrides := `
+name: null
+where:
+  city: null
+`
+
+	vals, err := ReadValues([]byte(overrides))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := MergeValues(c, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name, ok := v["name"]; !ok {
+		t.Error("Expected top-level null key 'name' to be kept")
+	} else if name != nil {
+		t.Errorf("Expected 'name' to be nil, got %v", name)
+	}
+
+	where := v["where"].(map[string]interface{})
+	if city, ok := where["city"]; !ok {
+		t.Error("Expected nested null key 'where.city' to be kept")
+	} else if city != nil {
+		t.Errorf("Expected 'where.city' to be nil, got %v", city)
+	}
+	if where["title"] != "whaler" {
+		t.Errorf("Expected 'where.title' to be 'whaler', got %v", where["title"])
+	}
+	if _, ok := vals["where"].(map[string]interface{})["title"]; ok {
+		t.Error("Expected the given values not to be modified")
+	}
+
+	// CoalesceValues, by contrast, removes the top-level null key.
+	cv, err := CoalesceValues(c, &chart.Config{Raw: overrides})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cv["name"]; ok {
+		t.Error("Expected CoalesceValues to remove null key 'name'")
+	}
+}
+
+func TestSubchartValues(t *testing.T) {
+	c, err := LoadDir("testdata/moby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vals, err := ReadValues([]byte(testCoalesceValuesYaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := SubchartValues(c, vals, "pequod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, err := v.PathValue("global.name"); err != nil || name != "Ishmael" {
+		t.Errorf("Expected pequod global.name to be 'Ishmael', got %v (%v)", name, err)
+	}
+	if name := v["name"]; name != "pequod" {
+		t.Errorf("Expected pequod name to be 'pequod', got %v", name)
+	}
+
+	v, err = SubchartValues(c, vals, "pequod.ahab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scope := v["scope"]; scope != "whale" {
+		t.Errorf("Expected ahab scope to be 'whale', got %v", scope)
+	}
+
+	if _, err := SubchartValues(c, vals, "nantucket"); err == nil {
+		t.Error("Expected an error for a missing subchart")
+	}
+	if _, ok := vals["name"]; ok {
+		t.Error("Expected vals not to be modified")
+	}
+}
+
+func TestExtractGlobals(t *testing.T) {
+	c, err := LoadDir("testdata/moby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := CoalesceValues(c, &chart.Config{Raw: testCoalesceValuesYaml})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := ExtractGlobals(v)
+	if g["name"] != "Ishmael" || g["subject"] != "Queequeg" {
+		t.Errorf("Expected the top-level globals, got %v", g)
+	}
+	pequod, err := v.Table("pequod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g := ExtractGlobals(pequod); g["harpooner"] != "Tashtego" {
+		t.Errorf("Expected pequod's globals, got %v", g)
+	}
+
+	for _, vals := range []map[string]interface{}{nil, {}, {"global": "none"}} {
+		if g := ExtractGlobals(vals); g == nil || len(g) != 0 {
+			t.Errorf("Expected an empty table for %v, got %v", vals, g)
+		}
+	}
+}
+
+func TestCoalesceValuesExplain(t *testing.T) {
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "moby"},
+		Values: &chart.Config{Raw: `
+name: moby
+global:
+  name: Ahab
+  subject: Moby Dick
+`},
+		Dependencies: []*chart.Chart{
+			{
+				Metadata: &chart.Metadata{Name: "pequod"},
+				Values:   &chart.Config{Raw: "scope: pequod\n"},
+			},
+		},
+	}
+	vals := map[string]interface{}{
+		"name": "moby",
+		"global": map[string]interface{}{
+			"name": "Ishmael",
+		},
+	}
+
+	v, overrides, err := CoalesceValuesExplain(c, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []Override{{Path: "global.name", Default: "Ahab", Value: "Ishmael"}}
+	if !reflect.DeepEqual(overrides, expect) {
+		t.Errorf("Expected overrides %v, got %v", expect, overrides)
+	}
+
+	for _, tt := range []struct {
+		tpl, expect string
+	}{
+		{"{{.global.name}}", "Ishmael"},
+		{"{{.global.subject}}", "Moby Dick"},
+		{"{{.pequod.global.name}}", "Ishmael"},
+		{"{{.pequod.scope}}", "pequod"},
+	} {
+		if o, err := ttpl(tt.tpl, v); err != nil || o != tt.expect {
+			t.Errorf("Expected %q to expand to %q, got %q", tt.tpl, tt.expect, o)
+		}
+	}
+
+	if _, ok := vals["pequod"]; ok {
+		t.Error("Expected the given values to be left unchanged")
+	}
+}
+
+func TestCoalesceGlobalsWithDepth(t *testing.T) {
+	c, err := LoadDir("testdata/moby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vals := map[string]interface{}{
+		"global": map[string]interface{}{"name": "Ishmael"},
+	}
+
+	tests := []struct {
+		depth        int
+		pequod, ahab bool
+		spouter      bool
+	}{
+		{-1, true, true, true},
+		{0, false, false, false},
+		{1, true, false, true},
+		{2, true, true, true},
+	}
+
+	for _, tt := range tests {
+		v, err := CoalesceGlobalsWithDepth(c, vals, tt.depth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, sub := range []struct {
+			path   string
+			expect bool
+		}{
+			{"pequod", tt.pequod},
+			{"pequod.ahab", tt.ahab},
+			{"spouter", tt.spouter},
+		} {
+			table, err := v.Table(sub.path)
+			if err != nil {
+				t.Fatalf("depth %d: %s", tt.depth, err)
+			}
+			_, ok := table[GlobalKey]
+			if ok != sub.expect {
+				t.Errorf("depth %d: expected globals in %s to be %t, got %t", tt.depth, sub.path, sub.expect, ok)
+			}
+		}
+		if name, _ := v.PathValue("global.name"); name != "Ishmael" {
+			t.Errorf("depth %d: expected global.name to be Ishmael, got %v", tt.depth, name)
+		}
+	}
+
+	if _, ok := vals["pequod"]; ok {
+		t.Error("Expected the given values to be left unchanged")
+	}
+}
+
+func TestValidateSubchartOverrides(t *testing.T) {
+	c, err := LoadDir("testdata/moby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vals, err := ReadValues([]byte(`
+name: Ishmael
+global:
+  name: Ishmael
+pequod:
+  scope: whale
+pequodd:
+  scope: whale
+spouter+replace:
+  scope: inn
+nantucket: true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []string{"nantucket", "pequodd"}
+	if got := ValidateSubchartOverrides(c, vals); !reflect.DeepEqual(got, expect) {
+		t.Errorf("Expected %v, got %v", expect, got)
+	}
+	if got := ValidateSubchartOverrides(c, nil); len(got) != 0 {
+		t.Errorf("Expected nothing for no values, got %v", got)
+	}
+}
+
+func TestValidateGlobals(t *testing.T) {
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "moby"},
+		Values: &chart.Config{Raw: `
+global:
+  name: Ishmael
+`},
+		Dependencies: []*chart.Chart{
+			{
+				Metadata: &chart.Metadata{Name: "pequod"},
+				Values: &chart.Config{Raw: `
+global:
+  name: Ahab
+  ship:
+    mast: tall
+`},
+				Dependencies: []*chart.Chart{
+					{
+						Metadata: &chart.Metadata{Name: "ahab"},
+						Values: &chart.Config{Raw: `
+global:
+  ship:
+    mast: short
+`},
+					},
+				},
+			},
+			{
+				Metadata: &chart.Metadata{Name: "spouter"},
+				Values: &chart.Config{Raw: `
+global:
+  name: Queequeg
+  ship:
+    mast: tall
+`},
+			},
+		},
+	}
+
+	conflicts, err := ValidateGlobals(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []GlobalConflict{
+		{
+			Key: "name",
+			Values: map[string]interface{}{
+				"moby":         "Ishmael",
+				"moby/pequod":  "Ahab",
+				"moby/spouter": "Queequeg",
+			},
+		},
+		{
+			Key: "ship.mast",
+			Values: map[string]interface{}{
+				"moby/pequod":      "tall",
+				"moby/pequod/ahab": "short",
+				"moby/spouter":     "tall",
+			},
+		},
+	}
+	if !reflect.DeepEqual(conflicts, expect) {
+		t.Errorf("Expected conflicts %v, got %v", expect, conflicts)
+	}
+
+	// A chart overriding its own dependency's global is not a conflict.
+	c.Dependencies = c.Dependencies[:1]
+	c.Dependencies[0].Dependencies = nil
+	conflicts, err = ValidateGlobals(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 0 {
+		t.Errorf("Expected no conflicts, got %v", conflicts)
+	}
+}
+
+func TestCoalesceValuesContext(t *testing.T) {
+	c, err := LoadDir("testdata/moby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vals, err := ReadValues([]byte(testCoalesceValuesYaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := CoalesceValuesContext(context.Background(), c, vals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, err := CoalesceValues(c, &chart.Config{Raw: testCoalesceValuesYaml})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, expect) {
+		t.Errorf("Expected %v, got %v", expect, v)
+	}
+	if _, ok := vals["spouter"]; ok {
+		t.Error("Expected the given values to be left unchanged")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := CoalesceValuesContext(ctx, c, vals); err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestCoalesceValuesReplacePaths(t *testing.T) {
+	defaults := `
+resources:
+  requests:
+    cpu: 100m
+  limits:
+    cpu: 200m
+web:
+  resources:
+    requests:
+      cpu: 100m
+  port: 80
+`
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "moby"},
+		Values:   &chart.Config{Raw: defaults},
+		Dependencies: []*chart.Chart{
+			{
+				Metadata: &chart.Metadata{Name: "pequod"},
+				Values:   &chart.Config{Raw: defaults},
+			},
+		},
+	}
+	vals, err := ReadValues([]byte(`
+resources:
+  limits:
+    cpu: 1
+web:
+  resources:
+    limits:
+      cpu: 1
+pequod:
+  resources:
+    limits:
+      cpu: 1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := CoalesceValuesWithOptions(c, vals, CoalesceValuesOptions{
+		ReplacePaths: []string{"resources", "web.resources", "*.resources"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"resources", "web.resources", "pequod.resources"} {
+		r, err := v.Table(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := r["requests"]; ok {
+			t.Errorf("Expected the chart's %s.requests to be dropped", p)
+		}
+		if cpu, _ := r.PathValue("limits.cpu"); cpu != float64(1) {
+			t.Errorf("Expected %s.limits.cpu to be 1, got %v", p, cpu)
+		}
+	}
+	if port, _ := v.PathValue("web.port"); port != float64(80) {
+		t.Errorf("Expected web.port to be merged from the chart, got %v", port)
+	}
+	if _, err := v.PathValue("pequod.web.resources.requests.cpu"); err != nil {
+		t.Errorf("Expected pequod.web.resources to be merged, got %v", err)
+	}
+
+	// Without the option, the tables are merged.
+	v, err = CoalesceValuesWithOptions(c, vals, CoalesceValuesOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cpu, _ := v.PathValue("resources.requests.cpu"); cpu != "100m" {
+		t.Errorf("Expected resources.requests.cpu to be merged, got %v", cpu)
+	}
+}
+
+func TestCoalesceValuesDirectives(t *testing.T) {
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "moby"},
+		Values: &chart.Config{Raw: `
+list: [one, two]
+resources:
+  requests:
+    cpu: 100m
+web:
+  resources:
+    requests:
+      cpu: 100m
+`},
+		Dependencies: []*chart.Chart{
+			{
+				Metadata: &chart.Metadata{Name: "pequod"},
+				Values:   &chart.Config{Raw: "resources:\n  requests:\n    cpu: 100m\n"},
+			},
+		},
+	}
+
+	vals, err := ReadValues([]byte(`
+list+replace: [three]
+resources+replace:
+  limits:
+    cpu: 1
+web:
+  resources+merge:
+    limits:
+      cpu: 1
+pequod:
+  resources+replace:
+    limits:
+      cpu: 1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without KeyDirectives, the suffixed keys are ordinary keys.
+	v, err := CoalesceValues(c, &chart.Config{Raw: "resources+replace:\n  limits:\n    cpu: 1\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cpu, _ := v.PathValue("resources.requests.cpu"); cpu != "100m" {
+		t.Errorf("Expected resources to be kept, got %v", cpu)
+	}
+	if _, ok := v["resources+replace"]; !ok {
+		t.Error("Expected resources+replace to be kept as a key")
+	}
+	if _, ok := v["resources"].(map[string]interface{})["limits"]; ok {
+		t.Error("Expected resources.limits not to be set")
+	}
+
+	opts := CoalesceValuesOptions{KeyDirectives: true}
+	v, err = CoalesceValuesWithOptions(c, vals, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := vals["list+replace"]; !ok {
+		t.Error("Expected the given values not to be modified")
+	}
+
+	if l := v["list"]; !reflect.DeepEqual(l, []interface{}{"three"}) {
+		t.Errorf("Expected list to be replaced, got %v", l)
+	}
+	if _, ok := v["list+replace"]; ok {
+		t.Error("Expected the directive to be stripped from list+replace")
+	}
+	if _, err := v.PathValue("resources.requests.cpu"); err == nil {
+		t.Error("Expected resources to be replaced")
+	}
+	if _, err := v.PathValue("pequod.resources.requests.cpu"); err == nil {
+		t.Error("Expected pequod.resources to be replaced")
+	}
+	if cpu, _ := v.PathValue("web.resources.requests.cpu"); cpu != "100m" {
+		t.Errorf("Expected web.resources to be merged, got %v", cpu)
+	}
+	if _, ok := v["web"].(map[string]interface{})["resources+merge"]; ok {
+		t.Error("Expected the directive to be stripped from resources+merge")
+	}
+
+	// A merge directive wins over a replaced path in the options.
+	vals, err = ReadValues([]byte("resources+merge:\n  limits:\n    cpu: 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.ReplacePaths = []string{"resources"}
+	v, err = CoalesceValuesWithOptions(c, vals, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cpu, _ := v.PathValue("resources.requests.cpu"); cpu != "100m" {
+		t.Errorf("Expected resources to be merged, got %v", cpu)
+	}
+
+	vals = Values{"list": []interface{}{"a"}, "list+replace": []interface{}{"b"}}
+	if _, err := CoalesceValuesWithOptions(c, vals, CoalesceValuesOptions{KeyDirectives: true}); err == nil {
+		t.Error("Expected an error for a key given both with and without a directive")
+	}
+}
+
+func TestCoalesceValuesOnSubchart(t *testing.T) {
+	c, err := LoadDir("testdata/moby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vals, err := ReadValues([]byte(testCoalesceValuesYaml))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := map[string]int{}
+	calls := 0
+	_, err = CoalesceValuesWithOptions(c, vals, CoalesceValuesOptions{
+		OnSubchart: func(name string, n int) {
+			calls++
+			keys[name] = n
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := []string{"pequod", "pequod.ahab", "spouter"}
+	if calls != len(expect) {
+		t.Errorf("Expected %d calls, got %d: %v", len(expect), calls, keys)
+	}
+	for _, name := range expect {
+		if n, ok := keys[name]; !ok {
+			t.Errorf("Expected a call for %s", name)
+		} else if n == 0 {
+			t.Errorf("Expected %s to be given some keys", name)
+		}
+	}
+	// pequod is given global with name, subject, harpooner and nested.boat
+	// and nested.sail, and ahab with scope.
+	if n := keys["pequod"]; n != 9 {
+		t.Errorf("Expected pequod to be given 9 keys, got %d", n)
+	}
+}
+
+func TestCoalesceValuesPreserveNullPaths(t *testing.T) {
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "moby"},
+		Values: &chart.Config{Raw: `
+debug: true
+secrets:
+  token: s3cr3t
+tls: true
+`},
+		Dependencies: []*chart.Chart{
+			{
+				Metadata: &chart.Metadata{Name: "pequod"},
+				Values:   &chart.Config{Raw: "tls: true\n"},
+			},
+		},
+	}
+	vals, err := ReadValues([]byte(`
+debug: null
+secrets:
+  token: null
+tls: null
+pequod:
+  tls: null
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := CoalesceValuesWithOptions(c, vals, CoalesceValuesOptions{
+		PreserveNullPaths: []string{"secrets", "tls", "*.tls"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := v["debug"]; ok {
+		t.Error("Expected 'debug: null' to be removed")
+	}
+	if token, err := v.PathValue("secrets.token"); err != nil || token != nil {
+		t.Errorf("Expected 'secrets.token: null' to be kept, got %v (%v)", token, err)
+	}
+	if tls, ok := v["tls"]; !ok || tls != nil {
+		t.Errorf("Expected 'tls: null' to be kept, got %v", tls)
+	}
+	if tls, err := v.PathValue("pequod.tls"); err != nil || tls != nil {
+		t.Errorf("Expected 'pequod.tls: null' to be kept, got %v (%v)", tls, err)
+	}
+
+	// Without the option, the top-level null is removed.
+	v, err = CoalesceValuesWithOptions(c, vals, CoalesceValuesOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v["tls"]; ok {
+		t.Error("Expected 'tls: null' to be removed")
+	}
+}
+
+func TestCoalesceTables(t *testing.T) {
+	dst := map[string]interface{}{
+		"name": "Ishmael",
+		"address": map[string]interface{}{
+			"street": "123 Spouter Inn Ct.",
+			"city":   "Nantucket",
+		},
+		"details": map[string]interface{}{
+			"friends": []string{"Tashtego"},
+		},
+		"boat": "pequod",
+	}
+	src := map[string]interface{}{
+		"occupation": "whaler",
+		"address": map[string]interface{}{
+			"state":  "MA",
+			"street": "234 Spouter Inn Ct.",
+		},
+		"details": "empty",
+		"boat": map[string]interface{}{
+			"mast": true,
+		},
+	}
+
+	// What we expect is that anything in dst overrides anything in src, but that
+	// otherwise the values are coalesced.
+	coalesceTables(dst, src, "")
+
+	if dst["name"] != "Ishmael" {
+		t.Errorf("Unexpected name: %s", dst["name"])
+	}
+	if dst["occupation"] != "whaler" {
+		t.Errorf("Unexpected occupation: %s", dst["occupation"])
+	}
+
+	addr, ok := dst["address"].(map[string]interface{})
+	if !ok {
+		t.Fatal("Address went away.")
+	}
+
+	if addr["street"].(string) != "123 Spouter Inn Ct." {
+		t.Errorf("Unexpected address: %v", addr["street"])
+	}
+
+	if addr["city"].(string) != "Nantucket" {
+		t.Errorf("Unexpected city: %v", addr["city"])
+	}
+
+	if addr["state"].(string) != "MA" {
+		t.Errorf("Unexpected state: %v", addr["state"])
+	}
+
+	if det, ok := dst["details"].(map[string]interface{}); !ok {
+		t.Fatalf("Details is the wrong type: %v", dst["details"])
+	} else if _, ok := det["friends"]; !ok {
+		t.Error("Could not find your friends. Maybe you don't have any. :-(")
+	}
+
+	if dst["boat"].(string) != "pequod" {
+		t.Errorf("Expected boat string, got %v", dst["boat"])
+	}
+}
+
+func TestCoalesceTablesStrict(t *testing.T) {
+	dst := map[string]interface{}{
+		"name": "Ishmael",
+		"details": map[string]interface{}{
+			"friends": []string{"Tashtego"},
+		},
+		"boat": "pequod",
+		"address": map[string]interface{}{
+			"street": map[string]interface{}{"number": 123},
+			"city":   nil,
+		},
+	}
+	src := map[string]interface{}{
+		"details": "empty",
+		"boat": map[string]interface{}{
+			"mast": true,
+		},
+		"address": map[string]interface{}{
+			"street": "234 Spouter Inn Ct.",
+			"city":   map[string]interface{}{"name": "Nantucket"},
+		},
+	}
+
+	err := CoalesceTablesStrict(dst, src)
+	mismatch, ok := err.(ErrTableMismatch)
+	if !ok {
+		t.Fatalf("Expected ErrTableMismatch, got %v", err)
+	}
+	if expect := []string{"address.street", "boat", "details"}; !reflect.DeepEqual(mismatch.Paths, expect) {
+		t.Errorf("Expected mismatches at %v, got %v", expect, mismatch.Paths)
+	}
+	if dst["boat"] != "pequod" {
+		t.Errorf("Expected dst to be left unchanged, got boat %v", dst["boat"])
+	}
+
+	dst = map[string]interface{}{
+		"name":    "Ishmael",
+		"address": map[string]interface{}{"city": "Nantucket"},
+	}
+	src = map[string]interface{}{
+		"occupation": "whaler",
+		"address":    map[string]interface{}{"state": "MA"},
+	}
+	if err := CoalesceTablesStrict(dst, src); err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]interface{}{
+		"name":       "Ishmael",
+		"occupation": "whaler",
+		"address":    map[string]interface{}{"city": "Nantucket", "state": "MA"},
+	}
+	if !reflect.DeepEqual(dst, expect) {
+		t.Errorf("Expected %v, got %v", expect, dst)
+	}
+}
+
+func TestCoalesceTablesNilDestination(t *testing.T) {
+	src := map[string]interface{}{
+		"name": "Ishmael",
+		"ship": map[string]interface{}{"name": "Pequod"},
+	}
+
+	dst := CoalesceTables(nil, src)
+	if !reflect.DeepEqual(dst, src) {
+		t.Errorf("Expected %v, got %v", src, dst)
+	}
+
+	dst, err := CoalesceTablesWithOptions(nil, src, CoalesceOptions{ListStrategy: ListAppend})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dst, src) {
+		t.Errorf("Expected %v, got %v", src, dst)
+	}
+
+	if dst := CoalesceTables(nil, nil); dst == nil {
+		t.Error("Expected a new map for a nil destination and source")
+	}
+}
+
+func TestCoalesceAtPath(t *testing.T) {
+	dst := map[string]interface{}{
+		"replicas": 3,
+		"image": map[string]interface{}{
+			"tag": "v2",
+		},
+	}
+	src := map[string]interface{}{
+		"repository": "whalers/pequod",
+		"tag":        "v1",
+		"replicas":   1,
+	}
+
+	if err := CoalesceAtPath(dst, src, "image"); err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]interface{}{
+		"replicas": 3,
+		"image": map[string]interface{}{
+			"repository": "whalers/pequod",
+			"tag":        "v2",
+			"replicas":   1,
+		},
+	}
+	if !reflect.DeepEqual(dst, expect) {
+		t.Errorf("Expected %v, got %v", expect, dst)
+	}
+
+	if err := CoalesceAtPath(dst, src, "sidecar.image"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := Values(dst).Table("sidecar.image"); err != nil || !reflect.DeepEqual(v, Values(src)) {
+		t.Errorf("Expected a new table with %v, got %v (%v)", src, v, err)
+	}
+
+	if err := CoalesceAtPath(dst, src, "replicas.image"); !isNotTable(err) {
+		t.Errorf("Expected ErrNotTable, got %v", err)
+	}
+	if err := CoalesceAtPath(dst, src, ""); pathCause(err) != ErrEmptyPath {
+		t.Errorf("Expected ErrEmptyPath, got %v", err)
+	}
+}
+
+// deepTable returns a table with the given number of nested tables, each
+// under the key "a", with leaf set at the bottom.
+func deepTable(depth int, leaf string) map[string]interface{} {
+	m := map[string]interface{}{leaf: true}
+	for i := 0; i < depth; i++ {
+		m = map[string]interface{}{"a": m}
+	}
+	return m
+}
+
+// isMaxDepth reports whether err is an ErrMaxDepth for the given depth.
+func isMaxDepth(err error, depth int) bool {
+	e, ok := err.(ErrMaxDepth)
+	return ok && e.Depth == depth
+}
+
+func TestCoalesceMaxDepth(t *testing.T) {
+	dst := deepTable(10, "dst")
+	_, err := CoalesceTablesWithOptions(dst, deepTable(10, "src"), CoalesceOptions{MaxDepth: 5})
+	if depthErr, ok := err.(ErrMaxDepth); !ok {
+		t.Errorf("Expected ErrMaxDepth for tables nested beyond the maximum depth, got %v", err)
+	} else if depthErr.Depth != 5 {
+		t.Errorf("Expected depth 5, got %d", depthErr.Depth)
+	}
+	if _, err := CoalesceTablesWithOptions(deepTable(4, "dst"), deepTable(4, "src"), CoalesceOptions{MaxDepth: 5}); err != nil {
+		t.Errorf("Expected no error for tables within the maximum depth, got %v", err)
+	}
+
+	raw, err := json.Marshal(deepTable(DefaultMaxDepth+10, "chart"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "moby"},
+		Values:   &chart.Config{Raw: string(raw)},
+	}
+	_, err = MergeValues(c, deepTable(DefaultMaxDepth+10, "vals"))
+	if depthErr, ok := err.(ErrMaxDepth); !ok {
+		t.Errorf("Expected ErrMaxDepth for deeply nested values, got %v", err)
+	} else if depthErr.Depth != DefaultMaxDepth {
+		t.Errorf("Expected depth %d, got %d", DefaultMaxDepth, depthErr.Depth)
+	}
+
+	// A chart that depends on itself is nested without end.
+	loop := &chart.Chart{Metadata: &chart.Metadata{Name: "loop"}}
+	loop.Dependencies = []*chart.Chart{loop}
+	if _, err := CoalesceValues(loop, nil); !isMaxDepth(err, DefaultMaxDepth) {
+		t.Errorf("Expected ErrMaxDepth for a chart that depends on itself, got %v", err)
+	}
+
+	// The limit can be lowered for both subcharts and values.
+	opts := CoalesceValuesOptions{MaxDepth: 3}
+	if _, err := CoalesceValuesWithOptions(loop, nil, opts); !isMaxDepth(err, 3) {
+		t.Errorf("Expected ErrMaxDepth of 3 for a chart that depends on itself, got %v", err)
+	}
+	shallow := &chart.Chart{
+		Metadata: &chart.Metadata{Name: "moby"},
+		Values:   &chart.Config{Raw: string(raw)},
+	}
+	if _, err := CoalesceValuesWithOptions(shallow, deepTable(5, "vals"), opts); !isMaxDepth(err, 3) {
+		t.Errorf("Expected ErrMaxDepth of 3 for deeply nested values, got %v", err)
+	}
+	if _, err := CoalesceValuesWithOptions(shallow, deepTable(2, "vals"), opts); err != nil {
+		t.Errorf("Expected no error for values within the limit, got %v", err)
+	}
+
+	// CoalesceTables has no error to return, so it leaves out the values
+	// nested too deeply.
+	bottom := CoalesceTables(deepTable(DefaultMaxDepth+5, "dst"), deepTable(DefaultMaxDepth+5, "src"))
+	for i := 0; i < DefaultMaxDepth+5; i++ {
+		bottom = bottom["a"].(map[string]interface{})
+	}
+	if _, ok := bottom["src"]; ok || bottom["dst"] != true {
+		t.Errorf("Expected only the dst leaf below the maximum depth, got %v", bottom)
+	}
+}
+
+func TestCoalesceValuesNestedDependencyErrors(t *testing.T) {
+	c, err := LoadDir("testdata/moby")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A value that should be a table for a subchart below the top-level
+	// chart's dependencies is not an error, as it never was.
+	v, err := CoalesceValues(c, &chart.Config{Raw: "pequod:\n  ahab: whale\n"})
+	if err != nil {
+		t.Fatalf("Expected no error for a nested non-table subchart value, got %s", err)
+	}
+	if ahab, _ := v.PathValue("pequod.ahab"); ahab != "whale" {
+		t.Errorf("Expected pequod.ahab to be kept, got %v", ahab)
+	}
+
+	// The top-level chart's own dependencies are still checked.
+	if _, err := CoalesceValues(c, &chart.Config{Raw: "pequod: whale\n"}); err == nil {
+		t.Error("Expected an error for a non-table subchart value")
+	}
+}
+
+func TestCoalesceTablesListStrategies(t *testing.T) {
+	ahab := map[string]interface{}{"name": "Ahab"}
+	starbuck := map[string]interface{}{"name": "Starbuck"}
+	stubb := map[string]interface{}{"name": "Stubb"}
+
+	tests := []struct {
+		strategy ListStrategy
+		expect   []interface{}
+	}{
+		{ListReplace, []interface{}{ahab}},
+		{ListAppend, []interface{}{ahab, starbuck, stubb}},
+		{ListPrepend, []interface{}{starbuck, stubb, ahab}},
+	}
+
+	for _, tt := range tests {
+		dst := map[string]interface{}{
+			"pequod": map[string]interface{}{
+				"crew": []interface{}{ahab},
+			},
+		}
+		src := map[string]interface{}{
+			"pequod": map[string]interface{}{
+				"crew": []interface{}{starbuck, stubb},
+			},
+		}
+
+		if _, err := CoalesceTablesWithOptions(dst, src, CoalesceOptions{ListStrategy: tt.strategy}); err != nil {
+			t.Fatal(err)
+		}
+
+		crew := dst["pequod"].(map[string]interface{})["crew"]
+		if !reflect.DeepEqual(tt.expect, crew) {
+			t.Errorf("Strategy %d: expected %v, got %v", tt.strategy, tt.expect, crew)
+		}
+	}
+
+	if s := (CoalesceOptions{}).ListStrategy; s != ListReplace {
+		t.Errorf("Expected ListReplace to be the default strategy, got %d", s)
+	}
+
+	// Unlike ListMergeByIndex, ListReplace ignores the source elements even for
+	// lists of the same length.
+	for strategy, expect := range map[ListStrategy]interface{}{
+		ListReplace:      map[string]interface{}{"name": "Ahab"},
+		ListMergeByIndex: map[string]interface{}{"name": "Ahab", "rank": "captain"},
+	} {
+		dst := map[string]interface{}{"crew": []interface{}{map[string]interface{}{"name": "Ahab"}}}
+		src := map[string]interface{}{"crew": []interface{}{map[string]interface{}{"name": "Starbuck", "rank": "captain"}}}
+		if _, err := CoalesceTablesWithOptions(dst, src, CoalesceOptions{ListStrategy: strategy}); err != nil {
+			t.Fatal(err)
+		}
+		if crew := dst["crew"].([]interface{}); !reflect.DeepEqual(expect, crew[0]) {
+			t.Errorf("Strategy %d: expected %v, got %v", strategy, expect, crew[0])
+		}
+	}
+
+	// CoalesceTables keeps the destination list.
+	dst := map[string]interface{}{"crew": []interface{}{ahab}}
+	CoalesceTables(dst, map[string]interface{}{"crew": []interface{}{starbuck}})
+	if expect := []interface{}{ahab}; !reflect.DeepEqual(expect, dst["crew"]) {
+		t.Errorf("Expected %v, got %v", expect, dst["crew"])
+	}
+}
+
+func TestCoalesceTablesMergeByIndex(t *testing.T) {
+	dst := map[string]interface{}{
+		"stages": []interface{}{
+			map[string]interface{}{"name": "build", "image": "golang"},
+			map[string]interface{}{"name": "test"},
+		},
+		"crew": []interface{}{"Ahab"},
+	}
+	src := map[string]interface{}{
+		"stages": []interface{}{
+			map[string]interface{}{"name": "compile", "timeout": 10},
+			map[string]interface{}{"name": "verify", "timeout": 5},
+		},
+		"crew": []interface{}{"Starbuck", "Stubb"},
+	}
+
+	if _, err := CoalesceTablesWithOptions(dst, src, CoalesceOptions{ListStrategy: ListMergeByIndex}); err != nil {
+		t.Fatal(err)
+	}
+
+	expect := map[string]interface{}{
+		"stages": []interface{}{
+			map[string]interface{}{"name": "build", "image": "golang", "timeout": 10},
+			map[string]interface{}{"name": "test", "timeout": 5},
+		},
+		// The lengths differ, so the source elements are appended.
+		"crew": []interface{}{"Ahab", "Starbuck", "Stubb"},
+	}
+	if !reflect.DeepEqual(expect, dst) {
+		t.Errorf("Expected %v, got %v", expect, dst)
+	}
+}
+
+func TestPathValue(t *testing.T) {
+	doc := `
+title: "Moby Dick"
+chapter:
+  one:
+    title: "Loomings"
+  two:
+    title: "The Carpet-Bag"
+  three:
+    title: "The Spouter Inn"
+`
+	d, err := ReadValues([]byte(doc))
+	if err != nil {
+		t.Fatalf("Failed to parse the White Whale: %s", err)
+	}
+
+	if v, err := d.PathValue("chapter.one.title"); err != nil {
+		t.Errorf("Got error instead of title: %s\n%v", err, d)
+	} else if v != "Loomings" {
+		t.Errorf("No error but got wrong value for title: %s\n%v", err, d)
+	}
+	if _, err := d.PathValue("chapter.one.doesntexist"); err == nil {
+		t.Errorf("Non-existent key should return error: %s\n%v", err, d)
+	}
+	if _, err := d.PathValue("chapter.doesntexist.one"); err == nil {
+		t.Errorf("Non-existent key in middle of path should return error: %s\n%v", err, d)
+	}
+	if _, err := d.PathValue(""); err == nil {
+		t.Error("Asking for the value from an empty path should yield an error")
+	}
+	if v, err := d.PathValue("title"); err == nil {
+		if v != "Moby Dick" {
+			t.Errorf("Failed to return values for root key title")
+		}
+	}
+}
+
+func TestPathExists(t *testing.T) {
+	d, err := ReadValues([]byte(`
+title: "Moby Dick"
+chapter:
+  one:
+    title: "Loomings"
+  two: null
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path   string
+		expect bool
+	}{
+		{"chapter.one.title", true},
+		{"chapter.one", true},
+		{"chapter.two", true},
+		{"title", true},
+		{"chapter.one.doesntexist", false},
+		{"chapter.doesntexist.title", false},
+		{"title.one", false},
+		{"chapter.two.title", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := d.PathExists(tt.path); got != tt.expect {
+			t.Errorf("Expected PathExists(%q) to be %t, got %t", tt.path, tt.expect, got)
+		}
+	}
+}
+
+func TestPathValueErrors(t *testing.T) {
+	d := Values{
+		"title": "Moby Dick",
+		"chapter": map[string]interface{}{
+			"one": map[string]interface{}{
+				"title": "Loomings",
+			},
+		},
+	}
+
+	if _, err := d.PathValue(""); pathCause(err) != ErrEmptyPath {
+		t.Errorf("Expected ErrEmptyPath, got %v", err)
+	}
+
+	tests := []struct {
+		path    string
+		segment string
+		is      func(error) bool
+		message string
+	}{
+		{"chapter.doesntexist.title", "doesntexist", IsNoTable, `no table named "doesntexist"`},
+		{"title.first", "title", isNotTable, `no table named "title"`},
+		{"chapter.one.doesntexist", "doesntexist", IsNoValue, "key not found: doesntexist"},
+		{"chapter.one", "one", IsNoValue, "key not found: one"},
+		{"doesntexist", "doesntexist", IsNoValue, "doesntexist is not a value"},
+	}
+	for _, tt := range tests {
+		_, err := d.PathValue(tt.path)
+		pe, ok := err.(*PathError)
+		if !ok {
+			t.Errorf("%s: expected a *PathError, got %v", tt.path, err)
+			continue
+		}
+		if pe.Path != tt.path || pe.Segment != tt.segment {
+			t.Errorf("%s: expected path %q and segment %q, got %q and %q", tt.path, tt.path, tt.segment, pe.Path, pe.Segment)
+		}
+		if !tt.is(err) {
+			t.Errorf("%s: unexpected error %v", tt.path, err)
+		}
+		if !strings.HasPrefix(err.Error(), tt.message) {
+			t.Errorf("%s: expected the message %q, got %q", tt.path, tt.message, err)
+		}
+	}
+
+	if _, err := d.Table("chapter.doesntexist"); !IsNoTable(err) || IsNoValue(err) {
+		t.Errorf("Expected ErrNoTable from Table, got %v", err)
+	} else if pe := err.(*PathError); pe.Segment != "doesntexist" {
+		t.Errorf("Expected segment 'doesntexist', got %q", pe.Segment)
+	}
+	if _, err := d.Table("title"); !isNotTable(err) || IsNoTable(err) {
+		t.Errorf("Expected ErrNotTable from Table, got %v", err)
+	}
+}
+
+// isNotTable reports whether err is an ErrNotTable, or a *PathError for one.
+func isNotTable(err error) bool {
+	_, ok := pathCause(err).(ErrNotTable)
+	return ok
+}
+
+func TestParseSet(t *testing.T) {
+	v, err := ParseSet(`poet=Coleridge,mariner.shot=ALBATROSS,stanza[1]=length,mariner.crew=200,mariner.alive=true,title=Rime\, of the Ancient Mariner,water\.where=everywhere`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := Values{
+		"poet":  "Coleridge",
+		"title": "Rime, of the Ancient Mariner",
+		"mariner": map[string]interface{}{
+			"shot":  "ALBATROSS",
+			"crew":  int64(200),
+			"alive": true,
+		},
+		"stanza":      []interface{}{nil, "length"},
+		"water.where": "everywhere",
+	}
+	if !reflect.DeepEqual(v, expect) {
+		t.Errorf("Expected %v, got %v", expect, v)
+	}
+
+	if _, err := ParseSet("poet"); err == nil {
+		t.Error("Expected an error for a key without a value")
+	}
+}
+
+func TestValuesApplySet(t *testing.T) {
+	data, err := ReadValuesFile("./testdata/coleridge.yaml")
+	if err != nil {
+		t.Fatalf("Error reading YAML file: %s", err)
+	}
+	if err := data.ApplySet("mariner.with=harpoon,water.water.nor=rum"); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, expect := range map[string]interface{}{
+		"mariner.with":      "harpoon",
+		"mariner.shot":      "ALBATROSS",
+		"water.water.nor":   "rum",
+		"water.water.where": "everywhere",
+		"poet":              "Coleridge",
+	} {
+		if v, err := data.PathValue(path); err != nil || v != expect {
+			t.Errorf("%s: expected %v, got %v (%v)", path, expect, v, err)
+		}
 	}
-	return b.String(), nil
 }
 
-// ref: http://www.yaml.org/spec/1.2/spec.html#id2803362
-var testCoalesceValuesYaml = `
-top: yup
-bottom: null
-right: Null
-left: NULL
-front: ~
-back: ""
+func TestValuesApplySetString(t *testing.T) {
+	typed := Values{}
+	if err := typed.ApplySet("ship.port=8080,ship.crew[0]=true"); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := typed.PathValue("ship.port"); v != int64(8080) {
+		t.Errorf("Expected ApplySet to give the number 8080, got %#v", v)
+	}
 
-global:
-  name: Ishmael
-  subject: Queequeg
-  nested:
-    boat: true
+	str := Values{}
+	if err := str.ApplySetString("ship.port=8080,ship.crew[0]=true"); err != nil {
+		t.Fatal(err)
+	}
+	expect := Values{
+		"ship": map[string]interface{}{
+			"port": "8080",
+			"crew": []interface{}{"true"},
+		},
+	}
+	if !reflect.DeepEqual(str, expect) {
+		t.Errorf("Expected %v, got %v", expect, str)
+	}
+}
 
-pequod:
-  global:
-    name: Stinky
-    harpooner: Tashtego
-    nested:
-      boat: false
-      sail: true
-  ahab:
-    scope: whale
-`
+func TestValuesMapLeaves(t *testing.T) {
+	v := Values{
+		"name": "moby",
+		"secrets": map[string]interface{}{
+			"password": "s3cr3t",
+			"tokens":   []interface{}{"abc", "def"},
+			"db": map[string]interface{}{
+				"password": "hunter2",
+			},
+		},
+	}
 
-func TestCoalesceValues(t *testing.T) {
-	tchart := "testdata/moby"
-	c, err := LoadDir(tchart)
+	var paths []string
+	v.MapLeaves(func(path string, value interface{}) interface{} {
+		paths = append(paths, path)
+		if strings.HasPrefix(path, "secrets.") {
+			return "***"
+		}
+		return value
+	})
+
+	expect := Values{
+		"name": "moby",
+		"secrets": map[string]interface{}{
+			"password": "***",
+			"tokens":   []interface{}{"***", "***"},
+			"db": map[string]interface{}{
+				"password": "***",
+			},
+		},
+	}
+	if !reflect.DeepEqual(v, expect) {
+		t.Errorf("Expected %v, got %v", expect, v)
+	}
+	expectPaths := []string{"name", "secrets.db.password", "secrets.password", "secrets.tokens[0]", "secrets.tokens[1]"}
+	if !reflect.DeepEqual(paths, expectPaths) {
+		t.Errorf("Expected paths %v, got %v", expectPaths, paths)
+	}
+}
+
+func TestValuesPaths(t *testing.T) {
+	data, err := ReadValuesFile("./testdata/coleridge.yaml")
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("Error reading YAML file: %s", err)
 	}
 
-	tvals := &chart.Config{Raw: testCoalesceValuesYaml}
+	expect := []string{
+		"mariner.shot",
+		"mariner.with",
+		"poet",
+		"stanza[0]",
+		"stanza[1]",
+		"stanza[2]",
+		"stanza[3]",
+		"stanza[4]",
+		"stanza[5]",
+		"title",
+		"water.water.nor",
+		"water.water.where",
+	}
+	if paths := data.Paths(); !reflect.DeepEqual(paths, expect) {
+		t.Errorf("Expected %v, got %v", expect, paths)
+	}
 
-	v, err := CoalesceValues(c, tvals)
+	if paths := (Values{}).Paths(); len(paths) != 0 {
+		t.Errorf("Expected no paths for empty values, got %v", paths)
+	}
+}
+
+func TestValuesFlatten(t *testing.T) {
+	data, err := ReadValuesFile("./testdata/coleridge.yaml")
 	if err != nil {
-		t.Fatal(err)
+		t.Fatalf("Error reading YAML file: %s", err)
+	}
+	data["albatross"] = map[string]interface{}{
+		"example.com/hung": "neck",
+		"crew":             []interface{}{map[string]interface{}{"fate": "dead"}},
+		"sails":            []interface{}{},
+		"wind":             map[string]interface{}{},
 	}
-	j, _ := json.MarshalIndent(v, "", "  ")
-	t.Logf("Coalesced Values: %s", string(j))
 
-	tests := []struct {
-		tpl    string
-		expect string
-	}{
-		{"{{.top}}", "yup"},
-		{"{{.back}}", ""},
-		{"{{.name}}", "moby"},
-		{"{{.global.name}}", "Ishmael"},
-		{"{{.global.subject}}", "Queequeg"},
-		{"{{.global.harpooner}}", "<no value>"},
-		{"{{.pequod.name}}", "pequod"},
-		{"{{.pequod.ahab.name}}", "ahab"},
-		{"{{.pequod.ahab.scope}}", "whale"},
-		{"{{.pequod.ahab.global.name}}", "Ishmael"},
-		{"{{.pequod.ahab.global.subject}}", "Queequeg"},
-		{"{{.pequod.ahab.global.harpooner}}", "Tashtego"},
-		{"{{.pequod.global.name}}", "Ishmael"},
-		{"{{.pequod.global.subject}}", "Queequeg"},
-		{"{{.spouter.global.name}}", "Ishmael"},
-		{"{{.spouter.global.harpooner}}", "<no value>"},
+	expect := map[string]interface{}{
+		"poet":                        "Coleridge",
+		"title":                       "Rime of the Ancient Mariner",
+		"stanza[0]":                   "at",
+		"stanza[1]":                   "length",
+		"stanza[2]":                   "did",
+		"stanza[3]":                   "cross",
+		"stanza[4]":                   "an",
+		"stanza[5]":                   "Albatross",
+		"mariner.with":                "crossbow",
+		"mariner.shot":                "ALBATROSS",
+		"water.water.where":           "everywhere",
+		"water.water.nor":             "any drop to drink",
+		`albatross.example\.com/hung`: "neck",
+		"albatross.crew[0].fate":      "dead",
+		"albatross.sails":             []interface{}{},
+		"albatross.wind":              map[string]interface{}{},
+	}
+	if flat := data.Flatten(); !reflect.DeepEqual(flat, expect) {
+		t.Errorf("Expected %v, got %v", expect, flat)
+	}
 
-		{"{{.global.nested.boat}}", "true"},
-		{"{{.pequod.global.nested.boat}}", "true"},
-		{"{{.spouter.global.nested.boat}}", "true"},
-		{"{{.pequod.global.nested.sail}}", "true"},
-		{"{{.spouter.global.nested.sail}}", "<no value>"},
+	if flat := (Values{}).Flatten(); len(flat) != 0 {
+		t.Errorf("Expected empty values to flatten to nothing, got %v", flat)
 	}
+}
 
-	for _, tt := range tests {
-		if o, err := ttpl(tt.tpl, v); err != nil || o != tt.expect {
-			t.Errorf("Expected %q to expand to %q, got %q", tt.tpl, tt.expect, o)
-		}
+func TestUnflatten(t *testing.T) {
+	data, err := ReadValuesFile("./testdata/coleridge.yaml")
+	if err != nil {
+		t.Fatalf("Error reading YAML file: %s", err)
+	}
+	data["albatross"] = map[string]interface{}{
+		"example.com/hung": "neck",
+		"crew":             []interface{}{map[string]interface{}{"fate": "dead"}},
+		"sails":            []interface{}{},
+		"wind":             map[string]interface{}{},
+		"grid":             []interface{}{[]interface{}{"a", "b"}},
 	}
 
-	nullKeys := []string{"bottom", "right", "left", "front"}
-	for _, nullKey := range nullKeys {
-		if _, ok := v[nullKey]; ok {
-			t.Errorf("Expected key %q to be removed, still present", nullKey)
+	v, err := Unflatten(data.Flatten())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, data) {
+		t.Errorf("Expected %v, got %v", data, v)
+	}
+
+	v, err = Unflatten(map[string]interface{}{"stanza[2]": "did"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := (Values{"stanza": []interface{}{nil, nil, "did"}}); !reflect.DeepEqual(v, expect) {
+		t.Errorf("Expected missing list elements to be nil, got %v", v)
+	}
+
+	for _, flat := range []map[string]interface{}{
+		{"a": 1, "a.b": 2},
+		{"a": 1, "a[0]": 2},
+		{"a[0]": 1, "a.b": 2},
+		{"a.b": 1, "a[0]": 2},
+		{"a": map[string]interface{}{}, "a.b": 2},
+		{"a[0]": 1, "a[00]": 2},
+		{"": 1},
+		{"a..b": 1},
+		{"a.": 1},
+		{"[0]": 1},
+		{"a[x]": 1},
+		{"a[0": 1},
+		{"a[0]b": 1},
+		{"a[0].": 1},
+		{`a\`: 1},
+	} {
+		if v, err := Unflatten(flat); err == nil {
+			t.Errorf("Expected an error for %v, got %v", flat, v)
 		}
 	}
 }
 
-func TestCoalesceTables(t *testing.T) {
-	dst := map[string]interface{}{
-		"name": "Ishmael",
-		"address": map[string]interface{}{
-			"street": "123 Spouter Inn Ct.",
-			"city":   "Nantucket",
+func TestValuesDig(t *testing.T) {
+	d := Values{
+		"title": "Moby Dick",
+		"chapter": map[string]interface{}{
+			"one": map[string]interface{}{"title": "Loomings"},
 		},
-		"details": map[string]interface{}{
-			"friends": []string{"Tashtego"},
+		"annotations": map[string]interface{}{
+			"example.com/owner": "Ishmael",
 		},
-		"boat": "pequod",
 	}
-	src := map[string]interface{}{
-		"occupation": "whaler",
-		"address": map[string]interface{}{
-			"state":  "MA",
-			"street": "234 Spouter Inn Ct.",
-		},
-		"details": "empty",
-		"boat": map[string]interface{}{
-			"mast": true,
+
+	if v, ok := d.Dig("chapter", "one", "title"); !ok || v != "Loomings" {
+		t.Errorf("Expected 'Loomings', got %v (%t)", v, ok)
+	}
+	if v, ok := d.Dig("annotations", "example.com/owner"); !ok || v != "Ishmael" {
+		t.Errorf("Expected 'Ishmael', got %v (%t)", v, ok)
+	}
+	if v, ok := d.Dig("chapter", "one"); !ok || !reflect.DeepEqual(v, map[string]interface{}{"title": "Loomings"}) {
+		t.Errorf("Expected the chapter one table, got %v (%t)", v, ok)
+	}
+	if v, ok := d.Dig("chapter", "two", "title"); ok || v != nil {
+		t.Errorf("Expected (nil, false) for a missing path, got (%v, %t)", v, ok)
+	}
+	if v, ok := d.Dig("title", "first"); ok || v != nil {
+		t.Errorf("Expected (nil, false) for a path through a scalar, got (%v, %t)", v, ok)
+	}
+	if v, ok := d.Dig(); !ok || !reflect.DeepEqual(v, d) {
+		t.Errorf("Expected the values themselves for no keys, got %v (%t)", v, ok)
+	}
+}
+
+func TestValuesKeys(t *testing.T) {
+	d := Values{
+		"title": "Moby Dick",
+		"chapter": map[string]interface{}{
+			"one":   map[string]interface{}{"title": "Loomings"},
+			"two":   map[string]interface{}{"title": "The Carpet-Bag"},
+			"three": map[string]interface{}{"title": "The Spouter Inn"},
 		},
 	}
 
-	// What we expect is that anything in dst overrides anything in src, but that
-	// otherwise the values are coalesced.
-	coalesceTables(dst, src, "")
+	keys, err := d.Keys("chapter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expect := []string{"one", "three", "two"}; !reflect.DeepEqual(keys, expect) {
+		t.Errorf("Expected %v, got %v", expect, keys)
+	}
 
-	if dst["name"] != "Ishmael" {
-		t.Errorf("Unexpected name: %s", dst["name"])
+	keys, err = d.Keys("")
+	if err != nil {
+		t.Fatal(err)
 	}
-	if dst["occupation"] != "whaler" {
-		t.Errorf("Unexpected occupation: %s", dst["occupation"])
+	if expect := []string{"chapter", "title"}; !reflect.DeepEqual(keys, expect) {
+		t.Errorf("Expected %v, got %v", expect, keys)
 	}
 
-	addr, ok := dst["address"].(map[string]interface{})
-	if !ok {
-		t.Fatal("Address went away.")
+	if _, err := d.Keys("title"); !isNotTable(err) {
+		t.Errorf("Expected ErrNotTable for a scalar, got %v", err)
 	}
+	if _, err := d.Keys("chapter.four"); !IsNoTable(err) {
+		t.Errorf("Expected ErrNoTable for a missing table, got %v", err)
+	}
+}
 
-	if addr["street"].(string) != "123 Spouter Inn Ct." {
-		t.Errorf("Unexpected address: %v", addr["street"])
+func TestPathValueDefault(t *testing.T) {
+	d := Values{
+		"title": "Moby Dick",
+		"mate":  nil,
+		"chapter": map[string]interface{}{
+			"one": map[string]interface{}{
+				"title": "Loomings",
+			},
+		},
 	}
 
-	if addr["city"].(string) != "Nantucket" {
-		t.Errorf("Unexpected city: %v", addr["city"])
+	tests := []struct {
+		path   string
+		expect interface{}
+	}{
+		{"chapter.one.title", "Loomings"},
+		{"title", "Moby Dick"},
+		{"mate", nil},
+		{"chapter.one.doesntexist", "default"},
+		{"chapter.doesntexist.title", "default"},
+		{"title.first", "default"},
+		{"chapter.one", "default"},
+		{"", "default"},
+	}
+	for _, tt := range tests {
+		if v := d.PathValueDefault(tt.path, "default"); v != tt.expect {
+			t.Errorf("%q: expected %v, got %v", tt.path, tt.expect, v)
+		}
+	}
+}
+
+func TestValuesTypedGetters(t *testing.T) {
+	d, err := ReadValues([]byte(`
+title: "Moby Dick"
+published: 1851
+whale:
+  white: true
+  length: 85.5
+chapter:
+  one:
+    title: "Loomings"
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse the White Whale: %s", err)
 	}
+	d["chapters"] = int64(135)
 
-	if addr["state"].(string) != "MA" {
-		t.Errorf("Unexpected state: %v", addr["state"])
+	if v, err := d.GetString("chapter.one.title"); err != nil || v != "Loomings" {
+		t.Errorf("Expected 'Loomings', got %q (%v)", v, err)
+	}
+	if v, err := d.GetInt("published"); err != nil || v != 1851 {
+		t.Errorf("Expected 1851, got %d (%v)", v, err)
+	}
+	if v, err := d.GetInt("chapters"); err != nil || v != 135 {
+		t.Errorf("Expected 135, got %d (%v)", v, err)
+	}
+	if v, err := d.GetBool("whale.white"); err != nil || !v {
+		t.Errorf("Expected true, got %t (%v)", v, err)
+	}
+	if v, err := d.GetFloat("whale.length"); err != nil || v != 85.5 {
+		t.Errorf("Expected 85.5, got %f (%v)", v, err)
+	}
+	if v, err := d.GetFloat("published"); err != nil || v != 1851 {
+		t.Errorf("Expected 1851, got %f (%v)", v, err)
 	}
 
-	if det, ok := dst["details"].(map[string]interface{}); !ok {
-		t.Fatalf("Details is the wrong type: %v", dst["details"])
-	} else if _, ok := det["friends"]; !ok {
-		t.Error("Could not find your friends. Maybe you don't have any. :-(")
+	_, err = d.GetInt("whale.length")
+	if wrongType, ok := err.(ErrWrongType); !ok {
+		t.Errorf("Expected ErrWrongType for a fractional number, got %v", err)
+	} else if wrongType.Path != "whale.length" || wrongType.Type != "int" {
+		t.Errorf("Expected path whale.length and type int, got %q and %q", wrongType.Path, wrongType.Type)
+	}
+	if _, err := d.GetString("published"); !isWrongType(err) {
+		t.Errorf("Expected ErrWrongType for a number, got %v", err)
 	}
 
-	if dst["boat"].(string) != "pequod" {
-		t.Errorf("Expected boat string, got %v", dst["boat"])
+	// One past the largest int, which depends on the platform.
+	d["overflow"] = map[string]interface{}{
+		"float":  float64(1 << 63),
+		"number": json.Number("9223372036854775808"),
+	}
+	if strconv.IntSize < 64 {
+		d["overflow"].(map[string]interface{})["int64"] = int64(math.MaxInt32) + 1
+	}
+	for k := range d["overflow"].(map[string]interface{}) {
+		if v, err := d.GetInt("overflow." + k); !isWrongType(err) {
+			t.Errorf("Expected ErrWrongType for overflow.%s, got %d (%v)", k, v, err)
+		}
+	}
+	if _, err := d.GetBool("title"); !isWrongType(err) {
+		t.Errorf("Expected ErrWrongType for a string, got %v", err)
+	}
+	if _, err := d.GetFloat("title"); !isWrongType(err) {
+		t.Errorf("Expected ErrWrongType for a string, got %v", err)
+	}
+
+	if _, err := d.GetString("chapter.two.title"); isWrongType(err) || err == nil {
+		t.Errorf("Expected a path error for a missing value, got %v", err)
+	}
+	if _, err := d.GetString("chapter.one.doesntexist"); !IsNoValue(err) {
+		t.Errorf("Expected ErrNoValue, got %v", err)
 	}
 }
-func TestPathValue(t *testing.T) {
+
+// isWrongType reports whether err is an ErrWrongType.
+func isWrongType(err error) bool {
+	_, ok := err.(ErrWrongType)
+	return ok
+}
+
+func TestDeletePathValue(t *testing.T) {
 	doc := `
 title: "Moby Dick"
 chapter:
@@ -430,32 +2818,60 @@ chapter:
     title: "Loomings"
   two:
     title: "The Carpet-Bag"
-  three:
-    title: "The Spouter Inn"
 `
 	d, err := ReadValues([]byte(doc))
 	if err != nil {
 		t.Fatalf("Failed to parse the White Whale: %s", err)
 	}
 
-	if v, err := d.PathValue("chapter.one.title"); err != nil {
-		t.Errorf("Got error instead of title: %s\n%v", err, d)
-	} else if v != "Loomings" {
-		t.Errorf("No error but got wrong value for title: %s\n%v", err, d)
+	if err := d.DeletePathValue("chapter.one.title"); err != nil {
+		t.Fatalf("Failed to delete chapter.one.title: %s", err)
 	}
-	if _, err := d.PathValue("chapter.one.doesntexist"); err == nil {
-		t.Errorf("Non-existent key should return error: %s\n%v", err, d)
+	if _, err := d.PathValue("chapter.one.title"); err == nil {
+		t.Error("Expected chapter.one.title to be deleted")
 	}
-	if _, err := d.PathValue("chapter.doesntexist.one"); err == nil {
-		t.Errorf("Non-existent key in middle of path should return error: %s\n%v", err, d)
+	// The now empty table is kept.
+	if _, err := d.Table("chapter.one"); err != nil {
+		t.Errorf("Expected chapter.one to be kept: %s", err)
 	}
-	if _, err := d.PathValue(""); err == nil {
-		t.Error("Asking for the value from an empty path should yield an error")
+
+	if err := d.DeletePathValue("chapter.two"); err != nil {
+		t.Fatalf("Failed to delete chapter.two: %s", err)
 	}
-	if v, err := d.PathValue("title"); err == nil {
-		if v != "Moby Dick" {
-			t.Errorf("Failed to return values for root key title")
-		}
+	if _, err := d.Table("chapter.two"); err == nil {
+		t.Error("Expected chapter.two to be deleted")
+	}
+
+	if err := d.DeletePathValue("chapter.three.title"); !IsNoTable(err) {
+		t.Errorf("Expected an ErrNoTable deleting through a missing table, got %v", err)
+	}
+	if err := d.DeletePathValue("title.name"); !isNotTable(err) {
+		t.Errorf("Expected an ErrNotTable deleting through a non-table, got %v", err)
+	}
+	if err := d.DeletePathValue("chapter.one.title"); !IsNoValue(err) {
+		t.Errorf("Expected an ErrNoValue deleting a missing key, got %v", err)
+	}
+	if err := d.DeletePathValue(""); err == nil {
+		t.Error("Expected an error deleting an empty path")
+	}
+}
+
+func TestPrunePathValue(t *testing.T) {
+	d := Values{
+		"title": "Moby Dick",
+		"chapter": map[string]interface{}{
+			"one": map[string]interface{}{
+				"title": "Loomings",
+			},
+		},
+	}
+
+	if err := d.PrunePathValue("chapter.one.title"); err != nil {
+		t.Fatalf("Failed to prune chapter.one.title: %s", err)
+	}
+	expect := Values{"title": "Moby Dick"}
+	if !reflect.DeepEqual(expect, d) {
+		t.Errorf("Expected %v, got %v", expect, d)
 	}
 }
 
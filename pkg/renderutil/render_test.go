@@ -74,7 +74,7 @@ data:
     IsInstall: false
     IsUpgrade: false
     Name: ""
-    Namespace: ""
+    Namespace: default
     Revision: 0
     Service: Tiller
     Time: null
@@ -102,7 +102,7 @@ data:
     IsInstall: false
     IsUpgrade: false
     Name: ""
-    Namespace: ""
+    Namespace: default
     Revision: 0
     Service: Tiller
     Time: null
@@ -130,7 +130,7 @@ data:
     IsInstall: false
     IsUpgrade: false
     Name: meow
-    Namespace: ""
+    Namespace: default
     Revision: 0
     Service: Tiller
     Time: null